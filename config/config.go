@@ -0,0 +1,257 @@
+// Package config holds the typed configuration of the service.
+//
+// Settings start from Default, are overridden by a YAML or TOML file, then by environment variables and finally by
+// command-line flags, and are validated before the service starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ecommerce-store/utilities"
+	"go.uber.org/zap/zapcore"
+)
+
+// Config is the configuration of the whole service
+type Config struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Storage    Storage    `yaml:"storage" toml:"storage"`
+	Logging    Logging    `yaml:"logging" toml:"logging"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	Auth       Auth       `yaml:"auth" toml:"auth"`
+	Checkout   Checkout   `yaml:"checkout" toml:"checkout"`
+	Promotions Promotions `yaml:"promotions" toml:"promotions"`
+	RateLimit  RateLimit  `yaml:"rate_limit" toml:"rate_limit"`
+	Tenants    []Tenant   `yaml:"tenants" toml:"tenants"` // Stores served by the process, a single store is served when empty
+}
+
+// Server configures the HTTP and gRPC listeners
+type Server struct {
+	Port              string   `yaml:"port" toml:"port"`                               // HTTP port
+	GRPCPort          string   `yaml:"grpc_port" toml:"grpc_port"`                     // gRPC port
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"` // Time allowed to read request headers
+	ShutdownTimeout   Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`       // Time allowed for in-flight requests to finish on shutdown
+}
+
+// Storage configures where the engine state is kept
+type Storage struct {
+	StateFile string `yaml:"state_file" toml:"state_file"` // File the state is saved to on shutdown, empty keeps it in memory only
+}
+
+// Logging configures the logger, see utilities.LoggerConfig
+type Logging struct {
+	Environment        string `yaml:"environment" toml:"environment"`                 // Environment the service runs in (e.g. dev, production)
+	Level              string `yaml:"level" toml:"level"`                             // Minimum level, derived from the environment when empty
+	Encoding           string `yaml:"encoding" toml:"encoding"`                       // json or console, derived from the environment when empty
+	Output             string `yaml:"output" toml:"output"`                           // stdout, stderr or a file path
+	MaxSizeMB          int    `yaml:"max_size_mb" toml:"max_size_mb"`                 // Size at which the log file is rotated
+	MaxAgeDays         int    `yaml:"max_age_days" toml:"max_age_days"`               // Days rotated log files are kept, 0 keeps them forever
+	MaxBackups         int    `yaml:"max_backups" toml:"max_backups"`                 // Rotated log files kept, 0 keeps all of them
+	Compress           bool   `yaml:"compress" toml:"compress"`                       // Compress rotated log files
+	SamplingInitial    int    `yaml:"sampling_initial" toml:"sampling_initial"`       // Entries with the same message logged per second before sampling
+	SamplingThereafter int    `yaml:"sampling_thereafter" toml:"sampling_thereafter"` // Every Nth entry logged after that, 0 disables sampling
+}
+
+// Tracing configures the trace exporter
+type Tracing struct {
+	Exporter string `yaml:"exporter" toml:"exporter"` // otlp, stdout or none
+}
+
+// Auth configures the secrets guarding privileged routes
+type Auth struct {
+	AdminToken    string `yaml:"admin_token" toml:"admin_token"`       // Bearer token required on /admin routes, empty leaves them open
+	WebhookSecret string `yaml:"webhook_secret" toml:"webhook_secret"` // Secret used to verify payment webhooks
+}
+
+// Checkout configures order placement
+type Checkout struct {
+	IdempotencyWindow Duration `yaml:"idempotency_window" toml:"idempotency_window"` // Lifetime of checkout idempotency keys
+}
+
+// Promotions configures discount coupons and loyalty points
+type Promotions struct {
+	DiscountInterval int     `yaml:"discount_interval" toml:"discount_interval"` // Every Nth order earns a discount coupon until an admin changes the policy
+	Loyalty          Loyalty `yaml:"loyalty" toml:"loyalty"`
+}
+
+// Loyalty configures how points are earned and redeemed
+type Loyalty struct {
+	Rate        float64            `yaml:"rate" toml:"rate"`               // Points earned per unit of currency spent
+	PointValue  float64            `yaml:"point_value" toml:"point_value"` // Currency value of a point when redeemed
+	Expiry      Duration           `yaml:"expiry" toml:"expiry"`           // Lifetime of earned points
+	Multipliers map[string]float64 `yaml:"multipliers" toml:"multipliers"` // Earning multipliers by product category
+}
+
+// RateLimit configures the requests allowed per client
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second" toml:"requests_per_second"` // Sustained rate per client IP, 0 disables limiting
+	Burst             int     `yaml:"burst" toml:"burst"`                             // Requests a client may make at once
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration such as "30s" or "24h"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText writes the duration in the form accepted by UnmarshalText
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used for settings that are not given
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:              "8080",
+			GRPCPort:          "9090",
+			ReadHeaderTimeout: Duration{10 * time.Second},
+			ShutdownTimeout:   Duration{30 * time.Second},
+		},
+		Logging: Logging{
+			Environment:        "dev",
+			Output:             "stdout",
+			MaxSizeMB:          100,
+			MaxAgeDays:         7,
+			MaxBackups:         5,
+			Compress:           true,
+			SamplingInitial:    100,
+			SamplingThereafter: 100,
+		},
+		Tracing: Tracing{
+			Exporter: utilities.TracingExporterNone,
+		},
+		Checkout: Checkout{
+			IdempotencyWindow: Duration{24 * time.Hour},
+		},
+		Promotions: Promotions{
+			DiscountInterval: 5,
+			Loyalty: Loyalty{
+				Rate:        1,                              // One point per unit spent
+				PointValue:  0.01,                           // Hundred points are worth one unit
+				Expiry:      Duration{365 * 24 * time.Hour}, // Points expire after a year
+				Multipliers: make(map[string]float64),
+			},
+		},
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(setting string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", setting, fmt.Sprintf(format, args...)))
+	}
+
+	if !validPort(c.Server.Port) {
+		invalid("server.port", "%q is not a port number", c.Server.Port)
+	}
+	if !validPort(c.Server.GRPCPort) {
+		invalid("server.grpc_port", "%q is not a port number", c.Server.GRPCPort)
+	}
+	if c.Server.Port == c.Server.GRPCPort {
+		invalid("server.grpc_port", "must differ from server.port")
+	}
+	if c.Server.ReadHeaderTimeout.Duration <= 0 {
+		invalid("server.read_header_timeout", "must be positive")
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		invalid("server.shutdown_timeout", "must be positive")
+	}
+
+	if c.Logging.Level != "" {
+		if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+			invalid("logging.level", "%q is not a log level", c.Logging.Level)
+		}
+	}
+	switch c.Logging.Encoding {
+	case "", utilities.EncodingJSON, utilities.EncodingConsole:
+	default:
+		invalid("logging.encoding", "must be %s or %s", utilities.EncodingJSON, utilities.EncodingConsole)
+	}
+	if c.Logging.Output == "" {
+		invalid("logging.output", "must be stdout, stderr or a file path")
+	}
+	if c.Logging.MaxSizeMB <= 0 {
+		invalid("logging.max_size_mb", "must be positive")
+	}
+	if c.Logging.MaxAgeDays < 0 || c.Logging.MaxBackups < 0 {
+		invalid("logging.max_age_days", "rotation settings must not be negative")
+	}
+	if c.Logging.SamplingInitial < 0 || c.Logging.SamplingThereafter < 0 {
+		invalid("logging.sampling_initial", "sampling settings must not be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case "", utilities.TracingExporterNone, utilities.TracingExporterStdout, utilities.TracingExporterOTLP:
+	default:
+		invalid("tracing.exporter", "must be %s, %s or %s", utilities.TracingExporterOTLP, utilities.TracingExporterStdout, utilities.TracingExporterNone)
+	}
+
+	if c.Checkout.IdempotencyWindow.Duration <= 0 {
+		invalid("checkout.idempotency_window", "must be positive")
+	}
+
+	if c.Promotions.DiscountInterval <= 0 {
+		invalid("promotions.discount_interval", "must be positive")
+	}
+	loyalty := c.Promotions.Loyalty
+	if loyalty.Rate < 0 {
+		invalid("promotions.loyalty.rate", "must not be negative")
+	}
+	if loyalty.PointValue < 0 {
+		invalid("promotions.loyalty.point_value", "must not be negative")
+	}
+	if loyalty.Expiry.Duration <= 0 {
+		invalid("promotions.loyalty.expiry", "must be positive")
+	}
+	for category, factor := range loyalty.Multipliers {
+		if factor < 0 {
+			invalid("promotions.loyalty.multipliers", "multiplier of %q must not be negative", category)
+		}
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		invalid("rate_limit.requests_per_second", "must not be negative")
+	}
+	if c.RateLimit.RequestsPerSecond > 0 && c.RateLimit.Burst < 1 {
+		invalid("rate_limit.burst", "must be at least 1 when rate limiting is enabled")
+	}
+
+	c.validateTenants(invalid)
+	return errors.Join(errs...)
+}
+
+// LoggerConfig returns the logger configuration for the service
+func (c *Config) LoggerConfig(serviceName string) utilities.LoggerConfig {
+	return utilities.LoggerConfig{
+		Environment:        c.Logging.Environment,
+		ServiceName:        serviceName,
+		Level:              c.Logging.Level,
+		Encoding:           c.Logging.Encoding,
+		Output:             c.Logging.Output,
+		MaxSizeMB:          c.Logging.MaxSizeMB,
+		MaxAgeDays:         c.Logging.MaxAgeDays,
+		MaxBackups:         c.Logging.MaxBackups,
+		Compress:           c.Logging.Compress,
+		SamplingInitial:    c.Logging.SamplingInitial,
+		SamplingThereafter: c.Logging.SamplingThereafter,
+	}.WithDefaults()
+}
+
+// validPort reports whether the value is a TCP port number
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port <= 65535
+}
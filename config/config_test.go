@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to write a config file into a temporary directory
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Test the defaults are valid
+func TestDefault_Valid(t *testing.T) {
+	// Act
+	err := Default().Validate()
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+// Test a YAML file overrides the defaults it sets and keeps the rest
+func TestLoad_YAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "8081"
+  shutdown_timeout: 5s
+storage:
+  state_file: /tmp/state.json
+promotions:
+  discount_interval: 3
+  loyalty:
+    multipliers:
+      books: 2
+rate_limit:
+  requests_per_second: 10
+  burst: 20
+`)
+
+	// Act
+	cfg, err := Load([]string{"-config", path})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "8081", cfg.Server.Port)
+	assert.Equal(t, "9090", cfg.Server.GRPCPort)
+	assert.Equal(t, 5*time.Second, cfg.Server.ShutdownTimeout.Duration)
+	assert.Equal(t, "/tmp/state.json", cfg.Storage.StateFile)
+	assert.Equal(t, 3, cfg.Promotions.DiscountInterval)
+	assert.Equal(t, map[string]float64{"books": 2}, cfg.Promotions.Loyalty.Multipliers)
+	assert.Equal(t, 0.01, cfg.Promotions.Loyalty.PointValue)
+	assert.Equal(t, RateLimit{RequestsPerSecond: 10, Burst: 20}, cfg.RateLimit)
+}
+
+// Test a TOML file is read like a YAML one
+func TestLoad_TOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+[server]
+grpc_port = "9091"
+
+[checkout]
+idempotency_window = "1h"
+
+[promotions.loyalty]
+expiry = "720h"
+`)
+
+	// Act
+	cfg, err := Load([]string{"-config", path})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "9091", cfg.Server.GRPCPort)
+	assert.Equal(t, time.Hour, cfg.Checkout.IdempotencyWindow.Duration)
+	assert.Equal(t, 720*time.Hour, cfg.Promotions.Loyalty.Expiry.Duration)
+}
+
+// Test env overrides the file and flags override env
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "server:\n  port: \"8081\"\npromotions:\n  discount_interval: 3\n")
+	t.Setenv(ConfigFileEnv, path)
+	t.Setenv(PortEnv, "8082")
+	t.Setenv(DiscountIntervalEnv, "4")
+	t.Setenv(LoyaltyMultipliersEnv, "books=2,toys=1.5")
+
+	// Act
+	cfg, err := Load([]string{"-port", "8083"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "8083", cfg.Server.Port)
+	assert.Equal(t, 4, cfg.Promotions.DiscountInterval)
+	assert.Equal(t, map[string]float64{"books": 2, "toys": 1.5}, cfg.Promotions.Loyalty.Multipliers)
+}
+
+// Test invalid settings stop the service from starting
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		args     []string
+		env      map[string]string
+		expected string
+	}{
+		{"unknown key", "server:\n  prot: \"8081\"\n", nil, nil, "field prot not found"},
+		{"unsupported file", "", []string{"-config", "config.json"}, nil, "unsupported config file"},
+		{"bad port", "", []string{"-port", "http"}, nil, "server.port"},
+		{"same ports", "", []string{"-port", "9090"}, nil, "must differ from server.port"},
+		{"bad duration", "", nil, map[string]string{ShutdownTimeoutEnv: "soon"}, "SHUTDOWN_TIMEOUT"},
+		{"bad level", "logging:\n  level: loud\n", nil, nil, "logging.level"},
+		{"bad interval", "", []string{"-discount-interval", "0"}, nil, "promotions.discount_interval"},
+		{"bad multiplier", "", nil, map[string]string{LoyaltyMultipliersEnv: "books"}, "LOYALTY_CATEGORY_MULTIPLIERS"},
+		{"burst required", "rate_limit:\n  requests_per_second: 5\n", nil, nil, "rate_limit.burst"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, "config.yaml", test.file)}, args...)
+			}
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			// Act
+			_, err := Load(args)
+
+			// Assert
+			assert.ErrorContains(t, err, test.expected)
+		})
+	}
+}
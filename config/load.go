@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecommerce-store/utilities"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by Load, in addition to the logging and tracing ones defined in utilities
+const (
+	ConfigFileEnv         = "CONFIG_FILE"                  // YAML or TOML file read before env and flags
+	PortEnv               = "PORT"                         // HTTP port
+	GRPCPortEnv           = "GRPC_PORT"                    // gRPC port
+	ShutdownTimeoutEnv    = "SHUTDOWN_TIMEOUT"             // Time allowed for in-flight requests to finish on shutdown (e.g. 30s)
+	StateFileEnv          = "STATE_FILE"                   // File the engine state is saved to on shutdown
+	AdminTokenEnv         = "ADMIN_TOKEN"                  // Bearer token required on /admin routes
+	WebhookSecretEnv      = "PAYMENT_WEBHOOK_SECRET"       // Secret used to verify payment webhooks
+	IdempotencyWindowEnv  = "IDEMPOTENCY_WINDOW"           // Lifetime of checkout idempotency keys (e.g. 24h)
+	DiscountIntervalEnv   = "DISCOUNT_INTERVAL"            // Every Nth order earns a discount coupon
+	LoyaltyRateEnv        = "LOYALTY_POINTS_RATE"          // Points earned per unit of currency spent (e.g. 1)
+	LoyaltyPointValueEnv  = "LOYALTY_POINT_VALUE"          // Currency value of a point when redeemed (e.g. 0.01)
+	LoyaltyExpiryEnv      = "LOYALTY_POINTS_EXPIRY"        // Lifetime of earned points (e.g. 8760h)
+	LoyaltyMultipliersEnv = "LOYALTY_CATEGORY_MULTIPLIERS" // Category multipliers (e.g. books=2,toys=1.5)
+	RateLimitEnv          = "RATE_LIMIT_RPS"               // Requests per second allowed per client, 0 disables limiting
+	RateLimitBurstEnv     = "RATE_LIMIT_BURST"             // Requests a client may make at once
+)
+
+// setting is a value that can be overridden by an environment variable and a command-line flag
+type setting struct {
+	env   string                      // Environment variable, empty if the setting has none
+	flag  string                      // Command-line flag, empty if the setting has none
+	usage string                      // Help shown for the flag
+	set   func(*Config, string) error // Parses the value into the config
+}
+
+// settings lists the overridable values; secrets have no flag so they don't show up in the process list
+var settings = []setting{
+	{PortEnv, "port", "HTTP port", setString(func(c *Config) *string { return &c.Server.Port })},
+	{GRPCPortEnv, "grpc-port", "gRPC port", setString(func(c *Config) *string { return &c.Server.GRPCPort })},
+	{ShutdownTimeoutEnv, "shutdown-timeout", "time allowed for in-flight requests to finish on shutdown", setDuration(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+	{StateFileEnv, "state-file", "file the engine state is saved to on shutdown", setString(func(c *Config) *string { return &c.Storage.StateFile })},
+	{utilities.AppEnvironmentEnv, "env", "environment the service runs in", setString(func(c *Config) *string { return &c.Logging.Environment })},
+	{utilities.LogLevelEnv, "log-level", "minimum log level", setString(func(c *Config) *string { return &c.Logging.Level })},
+	{utilities.LogEncodingEnv, "log-encoding", "log encoding (json or console)", setString(func(c *Config) *string { return &c.Logging.Encoding })},
+	{utilities.LogOutputEnv, "log-output", "stdout, stderr or a log file path", setString(func(c *Config) *string { return &c.Logging.Output })},
+	{utilities.LogMaxSizeEnv, "", "", setInt(func(c *Config) *int { return &c.Logging.MaxSizeMB })},
+	{utilities.LogMaxAgeEnv, "", "", setInt(func(c *Config) *int { return &c.Logging.MaxAgeDays })},
+	{utilities.LogMaxBackupsEnv, "", "", setInt(func(c *Config) *int { return &c.Logging.MaxBackups })},
+	{utilities.LogSamplingInitialEnv, "", "", setInt(func(c *Config) *int { return &c.Logging.SamplingInitial })},
+	{utilities.LogSamplingAfterEnv, "", "", setInt(func(c *Config) *int { return &c.Logging.SamplingThereafter })},
+	{utilities.TracingExporterEnv, "tracing-exporter", "trace exporter (otlp, stdout or none)", setString(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{AdminTokenEnv, "", "", setString(func(c *Config) *string { return &c.Auth.AdminToken })},
+	{WebhookSecretEnv, "", "", setString(func(c *Config) *string { return &c.Auth.WebhookSecret })},
+	{IdempotencyWindowEnv, "idempotency-window", "lifetime of checkout idempotency keys", setDuration(func(c *Config) *Duration { return &c.Checkout.IdempotencyWindow })},
+	{DiscountIntervalEnv, "discount-interval", "every Nth order earns a discount coupon", setInt(func(c *Config) *int { return &c.Promotions.DiscountInterval })},
+	{LoyaltyRateEnv, "", "", setFloat(func(c *Config) *float64 { return &c.Promotions.Loyalty.Rate })},
+	{LoyaltyPointValueEnv, "", "", setFloat(func(c *Config) *float64 { return &c.Promotions.Loyalty.PointValue })},
+	{LoyaltyExpiryEnv, "", "", setDuration(func(c *Config) *Duration { return &c.Promotions.Loyalty.Expiry })},
+	{LoyaltyMultipliersEnv, "", "", setMultipliers},
+	{RateLimitEnv, "rate-limit", "requests per second allowed per client, 0 disables limiting", setFloat(func(c *Config) *float64 { return &c.RateLimit.RequestsPerSecond })},
+	{RateLimitBurstEnv, "rate-limit-burst", "requests a client may make at once", setInt(func(c *Config) *int { return &c.RateLimit.Burst })},
+}
+
+// Load builds the configuration from the defaults, the config file, the environment and the command-line arguments, in increasing precedence
+func Load(args []string) (*Config, error) {
+	// Flags are applied last but parsed first, they may name the config file
+	flags := flag.NewFlagSet("ecommerce-store", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv(ConfigFileEnv), "YAML or TOML config file")
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var given []flagValue
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		flags.Func(s.flag, s.usage, func(value string) error {
+			given = append(given, flagValue{s, value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	if *path != "" {
+		if err := config.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.set(config, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+	for _, flagged := range given {
+		if err := flagged.setting.set(config, flagged.value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", flagged.setting.flag, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return config, nil
+}
+
+// readFile overrides the config with a YAML or TOML file, chosen by its extension. Unknown keys are rejected so typos don't go unnoticed.
+func (c *Config) readFile(path string) error {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension != ".yaml" && extension != ".yml" && extension != ".toml" {
+		return fmt.Errorf("unsupported config file %q, expected .yaml, .yml or .toml", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+	if extension == ".toml" {
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func setFloat(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", value)
+		}
+		field(c).Duration = parsed
+		return nil
+	}
+}
+
+// setMultipliers parses a list like "books=2,toys=1.5", replacing the multipliers of the config file
+func setMultipliers(c *Config, value string) error {
+	multipliers := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		category, factor, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return fmt.Errorf("%q is not a category=multiplier pair", pair)
+		}
+		parsed, err := strconv.ParseFloat(factor, 64)
+		if err != nil {
+			return fmt.Errorf("multiplier of %q is not a number", category)
+		}
+		multipliers[category] = parsed
+	}
+	c.Promotions.Loyalty.Multipliers = multipliers
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tenantIdPattern restricts tenant IDs to what can appear in the /tenants/{id} path prefix
+var tenantIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Tenant is a storefront with its own users, catalog, coupons and orders, served by the same process as the other tenants.
+// Settings left empty are inherited from the global configuration.
+type Tenant struct {
+	Id               string   `yaml:"id" toml:"id"`                               // Selects the tenant in the /tenants/{id} path prefix
+	Hosts            []string `yaml:"hosts" toml:"hosts"`                         // Host names whose requests are served by the tenant
+	GRPCPort         string   `yaml:"grpc_port" toml:"grpc_port"`                 // Port of the tenant's gRPC API, empty serves none
+	StateFile        string   `yaml:"state_file" toml:"state_file"`               // Defaults to the global state file with the tenant ID before its extension
+	AdminToken       string   `yaml:"admin_token" toml:"admin_token"`             // Bearer token required on the tenant's /admin routes
+	WebhookSecret    string   `yaml:"webhook_secret" toml:"webhook_secret"`       // Secret used to verify the tenant's payment webhooks
+	DiscountInterval int      `yaml:"discount_interval" toml:"discount_interval"` // Every Nth order earns a discount coupon
+	Loyalty          *Loyalty `yaml:"loyalty" toml:"loyalty"`                     // Replaces the global loyalty settings when given
+}
+
+// ForTenant returns the configuration of the tenant's engine and routes
+func (c *Config) ForTenant(tenant Tenant) *Config {
+	tenantConfig := *c
+	tenantConfig.Tenants = nil
+	tenantConfig.Promotions.Loyalty.Multipliers = copyMultipliers(c.Promotions.Loyalty.Multipliers)
+
+	tenantConfig.Server.GRPCPort = tenant.GRPCPort
+	tenantConfig.Storage.StateFile = tenant.StateFile
+	if tenant.StateFile == "" && c.Storage.StateFile != "" {
+		// Tenants never share a state file, /data/state.json becomes /data/state.acme.json
+		extension := filepath.Ext(c.Storage.StateFile)
+		tenantConfig.Storage.StateFile = strings.TrimSuffix(c.Storage.StateFile, extension) + "." + tenant.Id + extension
+	}
+	if tenant.AdminToken != "" {
+		tenantConfig.Auth.AdminToken = tenant.AdminToken
+	}
+	if tenant.WebhookSecret != "" {
+		tenantConfig.Auth.WebhookSecret = tenant.WebhookSecret
+	}
+	if tenant.DiscountInterval != 0 {
+		tenantConfig.Promotions.DiscountInterval = tenant.DiscountInterval
+	}
+	if tenant.Loyalty != nil {
+		tenantConfig.Promotions.Loyalty = *tenant.Loyalty
+		tenantConfig.Promotions.Loyalty.Multipliers = copyMultipliers(tenant.Loyalty.Multipliers)
+	}
+	return &tenantConfig
+}
+
+// validateTenants reports tenants that can't be told apart or would share data
+func (c *Config) validateTenants(invalid func(setting string, format string, args ...interface{})) {
+	ids := make(map[string]bool)
+	hosts := make(map[string]string)
+	grpcPorts := make(map[string]string)
+	stateFiles := make(map[string]string)
+	for i, tenant := range c.Tenants {
+		setting := fmt.Sprintf("tenants[%d]", i)
+		if !tenantIdPattern.MatchString(tenant.Id) {
+			invalid(setting+".id", "%q must be lowercase letters, digits and dashes", tenant.Id)
+		} else if ids[tenant.Id] {
+			invalid(setting+".id", "%q is used by another tenant", tenant.Id)
+		}
+		ids[tenant.Id] = true
+
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if other, ok := hosts[host]; ok {
+				invalid(setting+".hosts", "%q is already served by tenant %q", host, other)
+			}
+			hosts[host] = tenant.Id
+		}
+
+		tenantConfig := c.ForTenant(tenant)
+		if tenant.GRPCPort != "" {
+			if other, ok := grpcPorts[tenant.GRPCPort]; ok {
+				invalid(setting+".grpc_port", "%q is already used by %s", tenant.GRPCPort, other)
+			}
+			grpcPorts[tenant.GRPCPort] = setting + ".grpc_port"
+		}
+		if file := tenantConfig.Storage.StateFile; file != "" {
+			if other, ok := stateFiles[file]; ok {
+				invalid(setting+".state_file", "%q is already used by tenant %q", file, other)
+			}
+			stateFiles[file] = tenant.Id
+		}
+
+		// The gRPC port is optional for tenants, every other setting must hold as for a single store
+		if tenantConfig.Server.GRPCPort == "" {
+			tenantConfig.Server.GRPCPort = c.Server.GRPCPort
+		}
+		if err := tenantConfig.Validate(); err != nil {
+			invalid(setting, "%v", err)
+		}
+	}
+}
+
+// copyMultipliers returns a copy of the multipliers so tenants don't share the map
+func copyMultipliers(multipliers map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(multipliers))
+	for category, factor := range multipliers {
+		copied[category] = factor
+	}
+	return copied
+}
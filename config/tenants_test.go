@@ -0,0 +1,83 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a tenant inherits the global settings it doesn't override and gets its own state file
+func TestForTenant_Inherits(t *testing.T) {
+	cfg := Default()
+	cfg.Storage.StateFile = "/data/state.json"
+	cfg.Auth.AdminToken = "global-token"
+	cfg.Promotions.Loyalty.Multipliers["books"] = 2
+	cfg.Tenants = []Tenant{{Id: "acme", AdminToken: "acme-token", DiscountInterval: 3}}
+
+	// Act
+	tenantConfig := cfg.ForTenant(cfg.Tenants[0])
+	tenantConfig.Promotions.Loyalty.Multipliers["books"] = 3
+
+	// Assert
+	assert.Empty(t, tenantConfig.Tenants)
+	assert.Equal(t, "/data/state.acme.json", tenantConfig.Storage.StateFile)
+	assert.Equal(t, "acme-token", tenantConfig.Auth.AdminToken)
+	assert.Equal(t, 3, tenantConfig.Promotions.DiscountInterval)
+	assert.Equal(t, "", tenantConfig.Server.GRPCPort)
+	assert.Equal(t, cfg.Server.Port, tenantConfig.Server.Port)
+	assert.Equal(t, 2.0, cfg.Promotions.Loyalty.Multipliers["books"])
+}
+
+// Test tenants are loaded from the config file
+func TestLoad_Tenants(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+tenants:
+  - id: acme
+    hosts: [shop.acme.example]
+    grpc_port: "9091"
+  - id: globex
+    hosts: [globex.example]
+    loyalty:
+      rate: 2
+      point_value: 0.02
+      expiry: 720h
+`)
+
+	// Act
+	cfg, err := Load([]string{"-config", path})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Tenants, 2)
+	assert.Equal(t, []string{"shop.acme.example"}, cfg.Tenants[0].Hosts)
+	assert.Equal(t, "9091", cfg.Tenants[0].GRPCPort)
+	assert.Equal(t, 2.0, cfg.ForTenant(cfg.Tenants[1]).Promotions.Loyalty.Rate)
+}
+
+// Test tenants that can't be told apart or would share data are rejected
+func TestValidate_Tenants(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenants  []Tenant
+		expected string
+	}{
+		{"bad id", []Tenant{{Id: "Acme Corp"}}, "tenants[0].id"},
+		{"duplicate id", []Tenant{{Id: "acme"}, {Id: "acme"}}, "used by another tenant"},
+		{"duplicate host", []Tenant{{Id: "acme", Hosts: []string{"shop.example"}}, {Id: "globex", Hosts: []string{"SHOP.example"}}}, "already served by tenant \"acme\""},
+		{"duplicate grpc port", []Tenant{{Id: "acme", GRPCPort: "9091"}, {Id: "globex", GRPCPort: "9091"}}, "tenants[1].grpc_port"},
+		{"shared state file", []Tenant{{Id: "acme", StateFile: "/data/shop.json"}, {Id: "globex", StateFile: "/data/shop.json"}}, "tenants[1].state_file"},
+		{"invalid setting", []Tenant{{Id: "acme", DiscountInterval: -1}}, "promotions.discount_interval"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Tenants = test.tenants
+
+			// Act
+			err := cfg.Validate()
+
+			// Assert
+			assert.ErrorContains(t, err, test.expected)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecommerce-store/internal"
+)
+
+// Errors of resolvers acting on behalf of a user other than the caller
+var (
+	errCallerMissing = errors.New("X-User-Id header is required")
+	errForbidden     = errors.New("Callers can only act on their own data")
+)
+
+// errorCodes maps engine errors onto the codes returned in the extensions of GraphQL errors,
+// checked in order so specific errors come before the kind they wrap
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{errCallerMissing, "unauthorized"},
+	{errForbidden, "forbidden"},
+	{internal.ErrIdempotencyKeyReused, "idempotency_key_reused"},
+	{internal.ErrIdempotencyKeyInProgress, "idempotency_key_in_progress"},
+	{internal.ErrPaymentDeclined, "payment_declined"},
+	{internal.ErrNotFound, "not_found"},
+	{internal.ErrConflict, "conflict"},
+	{internal.ErrInsufficientStock, "insufficient_stock"},
+	{internal.ErrCouponInvalid, "coupon_invalid"},
+	{internal.ErrCouponExpired, "coupon_expired"},
+	{internal.ErrValidation, "validation_failed"},
+}
+
+// resolverError is an engine error carrying a machine-readable code, like the REST error envelope
+type resolverError struct {
+	err  error
+	code string
+}
+
+func (e *resolverError) Error() string {
+	return e.err.Error()
+}
+
+func (e *resolverError) Unwrap() error {
+	return e.err
+}
+
+// Extensions is added to the error in the response
+func (e *resolverError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// wrapError attaches the code of an engine error
+func wrapError(err error) error {
+	for _, mapping := range errorCodes {
+		if errors.Is(err, mapping.err) {
+			return &resolverError{err: err, code: mapping.code}
+		}
+	}
+	return &resolverError{err: err, code: "internal_error"}
+}
+
+// callerKey is the context key of the user the request claims to be
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the user the request claims to be, such as the X-User-Id header.
+// The claim isn't authenticated, it only keeps clients to their own data.
+func WithCaller(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, callerKey{}, userId)
+}
+
+// requireCaller rejects resolving on behalf of a user other than the one the request claims to be
+func requireCaller(ctx context.Context, userId string) error {
+	callerId, _ := ctx.Value(callerKey{}).(string)
+	if callerId == "" {
+		return wrapError(errCallerMissing)
+	}
+	if callerId != userId {
+		return wrapError(errForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+)
+
+// Product lookups made within this window of each other are fetched in one engine call
+const productBatchWait = 2 * time.Millisecond
+
+// Largest number of products fetched in one engine call
+const maxProductBatch = 100
+
+// productBatch is a set of product IDs fetched together
+type productBatch struct {
+	ids      []string                     // IDs to fetch
+	products map[string]*internal.Product // Fetched products, set before done is closed
+	done     chan struct{}                // Closed once the products are fetched
+	dispatch sync.Once                    // Fetches the batch once, whichever of the timer or a full batch comes first
+}
+
+// productLoader batches and caches the product lookups of a single request, so resolving the
+// product of every cart line or order item costs one engine call instead of one per line
+type productLoader struct {
+	svc     internal.ShoppingEngine  // Engine the products are fetched from
+	mutex   sync.Mutex               // Mutex to guard the pending batch and the cache
+	pending *productBatch            // Batch collecting IDs, nil if none
+	batches map[string]*productBatch // Batch each requested ID was fetched in
+}
+
+func newProductLoader(svc internal.ShoppingEngine) *productLoader {
+	return &productLoader{svc: svc, batches: make(map[string]*productBatch)}
+}
+
+// Load returns the product with the ID, or nil if there is none
+func (l *productLoader) Load(ctx context.Context, id string) *internal.Product {
+	return l.LoadMany(ctx, []string{id})[0]
+}
+
+// LoadMany returns the products with the IDs in the same order, nil for IDs that don't match a product
+func (l *productLoader) LoadMany(ctx context.Context, ids []string) []*internal.Product {
+	batches := make([]*productBatch, len(ids))
+	l.mutex.Lock()
+	for i, id := range ids {
+		batch, ok := l.batches[id]
+		if !ok {
+			if l.pending == nil {
+				pending := &productBatch{done: make(chan struct{})}
+				l.pending = pending
+				time.AfterFunc(productBatchWait, func() { l.fetch(ctx, pending) })
+			}
+			batch = l.pending
+			batch.ids = append(batch.ids, id)
+			l.batches[id] = batch
+			if len(batch.ids) >= maxProductBatch {
+				go l.fetch(ctx, batch)
+				l.pending = nil
+			}
+		}
+		batches[i] = batch
+	}
+	l.mutex.Unlock()
+
+	products := make([]*internal.Product, len(ids))
+	for i, batch := range batches {
+		select {
+		case <-batch.done:
+			products[i] = batch.products[ids[i]]
+		case <-ctx.Done():
+			return products
+		}
+	}
+	return products
+}
+
+// fetch closes the batch to new IDs and fetches its products
+func (l *productLoader) fetch(ctx context.Context, batch *productBatch) {
+	batch.dispatch.Do(func() {
+		l.mutex.Lock()
+		if l.pending == batch {
+			l.pending = nil
+		}
+		ids := batch.ids
+		l.mutex.Unlock()
+
+		batch.products = l.svc.GetProducts(ctx, ids)
+		close(batch.done)
+	})
+}
+
+// loaderKey is the context key of the request's product loader
+type loaderKey struct{}
+
+// withProductLoader returns a copy of ctx carrying a new product loader
+func withProductLoader(ctx context.Context, svc internal.ShoppingEngine) context.Context {
+	return context.WithValue(ctx, loaderKey{}, newProductLoader(svc))
+}
+
+// productLoaderFrom returns the loader carried by ctx, or a new one if there is none
+func productLoaderFrom(ctx context.Context, svc internal.ShoppingEngine) *productLoader {
+	if loader, ok := ctx.Value(loaderKey{}).(*productLoader); ok {
+		return loader
+	}
+	return newProductLoader(svc)
+}
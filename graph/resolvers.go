@@ -0,0 +1,327 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Resolver resolves the queries and mutations of the schema against the engine
+type Resolver struct {
+	svc internal.ShoppingEngine
+}
+
+func (r *Resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	user, err := r.svc.GetUser(ctx, string(args.ID))
+	if errors.Is(err, internal.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &userResolver{svc: r.svc, user: user}, nil
+}
+
+func (r *Resolver) Product(ctx context.Context, args struct{ ID graphql.ID }) *productResolver {
+	return newProductResolver(r.svc, productLoaderFrom(ctx, r.svc).Load(ctx, string(args.ID)))
+}
+
+func (r *Resolver) Products(ctx context.Context, args struct{ IDs []graphql.ID }) []*productResolver {
+	ids := make([]string, len(args.IDs))
+	for i, id := range args.IDs {
+		ids[i] = string(id)
+	}
+	products := productLoaderFrom(ctx, r.svc).LoadMany(ctx, ids)
+	resolvers := make([]*productResolver, len(products))
+	for i, product := range products {
+		resolvers[i] = newProductResolver(r.svc, product)
+	}
+	return resolvers
+}
+
+func (r *Resolver) Seller(ctx context.Context, args struct{ ID graphql.ID }) (*sellerResolver, error) {
+	return newSellerResolver(ctx, r.svc, string(args.ID))
+}
+
+func (r *Resolver) Order(ctx context.Context, args struct {
+	UserID graphql.ID
+	ID     graphql.ID
+}) (*orderResolver, error) {
+	if err := requireCaller(ctx, string(args.UserID)); err != nil {
+		return nil, err
+	}
+	order, err := r.svc.GetOrder(ctx, string(args.UserID), string(args.ID))
+	if errors.Is(err, internal.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &orderResolver{svc: r.svc, order: order}, nil
+}
+
+func (r *Resolver) AddToCart(ctx context.Context, args struct {
+	UserID    graphql.ID
+	ProductID graphql.ID
+	Quantity  int32
+}) (*cartResolver, error) {
+	if err := requireCaller(ctx, string(args.UserID)); err != nil {
+		return nil, err
+	}
+	cart, err := r.svc.AddToCart(ctx, string(args.UserID), string(args.ProductID), int(args.Quantity))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return newCartResolver(r.svc, cart), nil
+}
+
+// checkoutInput mirrors the CheckoutInput type of the schema
+type checkoutInput struct {
+	UserID         graphql.ID
+	CouponCode     *string
+	WalletAmount   *float64
+	RedeemPoints   *int32
+	IdempotencyKey *string
+}
+
+func (r *Resolver) Checkout(ctx context.Context, args struct{ Input checkoutInput }) (*checkoutPayloadResolver, error) {
+	input := args.Input
+	if err := requireCaller(ctx, string(input.UserID)); err != nil {
+		return nil, err
+	}
+	var options internal.CheckoutOptions
+	if input.CouponCode != nil {
+		options.CouponCode = *input.CouponCode
+	}
+	if input.WalletAmount != nil {
+		options.WalletAmount = *input.WalletAmount
+	}
+	if input.RedeemPoints != nil {
+		options.RedeemPoints = int(*input.RedeemPoints)
+	}
+
+	// Place the order, at most once per idempotency key if one is supplied
+	var order *internal.Order
+	var replayed bool
+	var err error
+	if input.IdempotencyKey != nil && *input.IdempotencyKey != "" {
+		order, replayed, err = r.svc.CheckoutWithIdempotencyKey(ctx, *input.IdempotencyKey, string(input.UserID), options)
+	} else {
+		order, err = r.svc.CheckoutWithOptions(ctx, string(input.UserID), options)
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &checkoutPayloadResolver{order: &orderResolver{svc: r.svc, order: order}, replayed: replayed}, nil
+}
+
+type userResolver struct {
+	svc  internal.ShoppingEngine
+	user *internal.User
+}
+
+func (r *userResolver) ID() graphql.ID { return graphql.ID(r.user.Id) }
+func (r *userResolver) Name() string   { return r.user.Name }
+func (r *userResolver) Email() string  { return r.user.Email }
+
+func (r *userResolver) Cart(ctx context.Context) (*cartResolver, error) {
+	cart, err := r.svc.GetCart(ctx, r.user.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return newCartResolver(r.svc, cart), nil
+}
+
+func (r *userResolver) Orders(ctx context.Context) ([]*orderResolver, error) {
+	orders, err := r.svc.GetOrders(ctx, r.user.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	resolvers := make([]*orderResolver, len(orders))
+	for i, order := range orders {
+		resolvers[i] = &orderResolver{svc: r.svc, order: order}
+	}
+	return resolvers, nil
+}
+
+type sellerResolver struct {
+	svc        internal.ShoppingEngine
+	user       *internal.User
+	productIds []string
+	unitsSold  int
+	revenue    float64
+}
+
+// newSellerResolver looks up the seller and their sales figures, returning nil if the seller doesn't exist
+func newSellerResolver(ctx context.Context, svc internal.ShoppingEngine, sellerId string) (*sellerResolver, error) {
+	user, err := svc.GetUser(ctx, sellerId)
+	if errors.Is(err, internal.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	analytics, err := svc.GetSellerAnalytics(ctx, sellerId, 0)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	resolver := &sellerResolver{svc: svc, user: user, unitsSold: analytics.UnitsSold, revenue: analytics.Revenue}
+	for _, performance := range analytics.Products {
+		resolver.productIds = append(resolver.productIds, performance.ProductId)
+	}
+	return resolver, nil
+}
+
+func (r *sellerResolver) ID() graphql.ID   { return graphql.ID(r.user.Id) }
+func (r *sellerResolver) Name() string     { return r.user.Name }
+func (r *sellerResolver) UnitsSold() int32 { return int32(r.unitsSold) }
+func (r *sellerResolver) Revenue() float64 { return r.revenue }
+
+// Products leaves out products that were removed
+func (r *sellerResolver) Products(ctx context.Context) []*productResolver {
+	resolvers := []*productResolver{}
+	for _, product := range productLoaderFrom(ctx, r.svc).LoadMany(ctx, r.productIds) {
+		if product != nil {
+			resolvers = append(resolvers, newProductResolver(r.svc, product))
+		}
+	}
+	return resolvers
+}
+
+type productResolver struct {
+	svc     internal.ShoppingEngine
+	product *internal.Product
+}
+
+// newProductResolver returns nil for a missing product, so it resolves to null
+func newProductResolver(svc internal.ShoppingEngine, product *internal.Product) *productResolver {
+	if product == nil {
+		return nil
+	}
+	return &productResolver{svc: svc, product: product}
+}
+
+func (r *productResolver) ID() graphql.ID      { return graphql.ID(r.product.Id) }
+func (r *productResolver) Name() string        { return r.product.Name }
+func (r *productResolver) Description() string { return r.product.Description }
+func (r *productResolver) Price() float64      { return r.product.Price }
+func (r *productResolver) Quantity() int32     { return int32(r.product.Quantity) }
+func (r *productResolver) Category() string    { return r.product.Category }
+
+func (r *productResolver) Seller(ctx context.Context) (*sellerResolver, error) {
+	return newSellerResolver(ctx, r.svc, r.product.SellerId)
+}
+
+type cartResolver struct {
+	svc   internal.ShoppingEngine
+	lines []cartLine
+}
+
+// cartLine is a product and its quantity in a cart
+type cartLine struct {
+	productId string
+	quantity  int
+}
+
+// newCartResolver snapshots the cart with its lines ordered by product ID
+func newCartResolver(svc internal.ShoppingEngine, cart map[string]int) *cartResolver {
+	resolver := &cartResolver{svc: svc}
+	for productId, quantity := range cart {
+		resolver.lines = append(resolver.lines, cartLine{productId: productId, quantity: quantity})
+	}
+	sort.Slice(resolver.lines, func(i, j int) bool {
+		return resolver.lines[i].productId < resolver.lines[j].productId
+	})
+	return resolver
+}
+
+func (r *cartResolver) Lines() []*cartLineResolver {
+	resolvers := make([]*cartLineResolver, len(r.lines))
+	for i, line := range r.lines {
+		resolvers[i] = &cartLineResolver{svc: r.svc, line: line}
+	}
+	return resolvers
+}
+
+func (r *cartResolver) Total(ctx context.Context) float64 {
+	ids := make([]string, len(r.lines))
+	for i, line := range r.lines {
+		ids[i] = line.productId
+	}
+	var total float64
+	for i, product := range productLoaderFrom(ctx, r.svc).LoadMany(ctx, ids) {
+		if product != nil {
+			total += product.Price * float64(r.lines[i].quantity)
+		}
+	}
+	return total
+}
+
+type cartLineResolver struct {
+	svc  internal.ShoppingEngine
+	line cartLine
+}
+
+func (r *cartLineResolver) Product(ctx context.Context) *productResolver {
+	return newProductResolver(r.svc, productLoaderFrom(ctx, r.svc).Load(ctx, r.line.productId))
+}
+
+func (r *cartLineResolver) Quantity() int32 { return int32(r.line.quantity) }
+
+type orderResolver struct {
+	svc   internal.ShoppingEngine
+	order *internal.Order
+}
+
+func (r *orderResolver) ID() graphql.ID          { return graphql.ID(r.order.Id) }
+func (r *orderResolver) Amount() float64         { return r.order.CartTotal }
+func (r *orderResolver) Discount() float64       { return r.order.Discount }
+func (r *orderResolver) DiscountCoupon() string  { return r.order.DiscountCoupon }
+func (r *orderResolver) AmountToPay() float64    { return r.order.AmountToPay }
+func (r *orderResolver) PaidFromWallet() float64 { return r.order.PaidFromWallet }
+func (r *orderResolver) PaidByProvider() float64 { return r.order.PaidByProvider }
+func (r *orderResolver) PointsRedeemed() int32   { return int32(r.order.PointsRedeemed) }
+func (r *orderResolver) PointsEarned() int32     { return int32(r.order.PointsEarned) }
+func (r *orderResolver) Status() string          { return strings.ToUpper(string(r.order.Status)) }
+func (r *orderResolver) CreatedAt() string       { return r.order.CreatedAt.Format(time.RFC3339) }
+
+func (r *orderResolver) User(ctx context.Context) (*userResolver, error) {
+	return (&Resolver{svc: r.svc}).User(ctx, struct{ ID graphql.ID }{graphql.ID(r.order.UserId)})
+}
+
+func (r *orderResolver) Items() []*lineItemResolver {
+	resolvers := make([]*lineItemResolver, len(r.order.Items))
+	for i, item := range r.order.Items {
+		resolvers[i] = &lineItemResolver{svc: r.svc, item: item}
+	}
+	return resolvers
+}
+
+type lineItemResolver struct {
+	svc  internal.ShoppingEngine
+	item *internal.LineItem
+}
+
+func (r *lineItemResolver) ProductID() graphql.ID { return graphql.ID(r.item.ProductId) }
+func (r *lineItemResolver) Name() string          { return r.item.Name }
+func (r *lineItemResolver) UnitPrice() float64    { return r.item.UnitPrice }
+func (r *lineItemResolver) Quantity() int32       { return int32(r.item.Quantity) }
+func (r *lineItemResolver) LineTotal() float64    { return r.item.LineTotal }
+func (r *lineItemResolver) Discount() float64     { return r.item.Discount }
+
+func (r *lineItemResolver) Product(ctx context.Context) *productResolver {
+	return newProductResolver(r.svc, productLoaderFrom(ctx, r.svc).Load(ctx, r.item.ProductId))
+}
+
+type checkoutPayloadResolver struct {
+	order    *orderResolver
+	replayed bool
+}
+
+func (r *checkoutPayloadResolver) Order() *orderResolver { return r.order }
+func (r *checkoutPayloadResolver) Replayed() bool        { return r.replayed }
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/ecommerce-store/internal"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// schemaSource is the GraphQL schema of the storefront
+//
+//go:embed schema.graphql
+var schemaSource string
+
+// Deepest query accepted, which is enough for user -> orders -> items -> product -> seller -> products
+const maxQueryDepth = 8
+
+// Executor runs GraphQL requests against the engine
+type Executor struct {
+	svc    internal.ShoppingEngine
+	schema *graphql.Schema
+}
+
+// NewExecutor parses the schema and binds it to the engine
+func NewExecutor(svc internal.ShoppingEngine) *Executor {
+	return &Executor{
+		svc:    svc,
+		schema: graphql.MustParseSchema(schemaSource, &Resolver{svc: svc}, graphql.MaxDepth(maxQueryDepth)),
+	}
+}
+
+// Execute runs a query or mutation, batching the product lookups made while resolving it
+func (e *Executor) Execute(ctx context.Context, query string, operationName string, variables map[string]interface{}) *graphql.Response {
+	return e.schema.Exec(withProductLoader(ctx, e.svc), query, operationName, variables)
+}
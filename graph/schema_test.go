@@ -0,0 +1,180 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/google/uuid"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingEngine records the product lookups that reach the engine
+type countingEngine struct {
+	internal.ShoppingEngine
+	mutex   sync.Mutex
+	batches [][]string
+}
+
+func (e *countingEngine) GetProducts(ctx context.Context, productIds []string) map[string]*internal.Product {
+	e.mutex.Lock()
+	e.batches = append(e.batches, productIds)
+	e.mutex.Unlock()
+	return e.ShoppingEngine.GetProducts(ctx, productIds)
+}
+
+// Helper function to register a user with three products in the cart
+func createUserWithCart(t *testing.T, svc internal.ShoppingEngine) (*internal.User, []*internal.Product) {
+	ctx := context.Background()
+	seller, err := svc.RegisterUser(ctx, "Seller", uuid.NewString()+"@example.com")
+	assert.NoError(t, err)
+	user, err := svc.RegisterUser(ctx, "Buyer", uuid.NewString()+"@example.com")
+	assert.NoError(t, err)
+
+	var products []*internal.Product
+	for i, name := range []string{"Product 1", "Product 2", "Product 3"} {
+		product, err := svc.RegisterProduct(ctx, name, "Description of "+name, 10, seller.Id, float64(10*(i+1)))
+		assert.NoError(t, err)
+		_, err = svc.AddToCart(ctx, user.Id, product.Id, 1)
+		assert.NoError(t, err)
+		products = append(products, product)
+	}
+	return user, products
+}
+
+// Helper function to run a query as the caller and decode its data
+func execute(t *testing.T, executor *Executor, callerId string, query string, variables map[string]interface{}, data interface{}) []*gqlerrors.QueryError {
+	response := executor.Execute(WithCaller(context.Background(), callerId), query, "", variables)
+	if data != nil && response.Data != nil {
+		assert.NoError(t, json.Unmarshal(response.Data, data))
+	}
+	return response.Errors
+}
+
+// Test the products of every cart line are fetched in a single engine call
+func TestCart_BatchesProductLookups(t *testing.T) {
+	svc := &countingEngine{ShoppingEngine: internal.NewEngine(nil)}
+	user, products := createUserWithCart(t, svc)
+	executor := NewExecutor(svc)
+
+	// Act
+	var data struct {
+		User struct {
+			Cart struct {
+				Lines []struct {
+					Product  struct{ Name string }
+					Quantity int
+				}
+				Total float64
+			}
+		}
+	}
+	errs := execute(t, executor, user.Id, `query($id: ID!) { user(id: $id) { cart { lines { product { name } quantity } total } } }`,
+		map[string]interface{}{"id": user.Id}, &data)
+
+	// Assert
+	assert.Empty(t, errs)
+	assert.Len(t, data.User.Cart.Lines, 3)
+	assert.Equal(t, 60.0, data.User.Cart.Total)
+	assert.Len(t, svc.batches, 1)
+	assert.ElementsMatch(t, []string{products[0].Id, products[1].Id, products[2].Id}, svc.batches[0])
+}
+
+// Test checkout places the order and it shows up in the user's orders
+func TestCheckout_Mutation(t *testing.T) {
+	svc := internal.NewEngine(nil)
+	user, products := createUserWithCart(t, svc)
+	executor := NewExecutor(svc)
+
+	// Act
+	var placed struct {
+		Checkout struct {
+			Order struct {
+				ID     string
+				Status string
+				Amount float64
+			}
+			Replayed bool
+		}
+	}
+	errs := execute(t, executor, user.Id, `mutation($input: CheckoutInput!) { checkout(input: $input) { order { id status amount } replayed } }`,
+		map[string]interface{}{"input": map[string]interface{}{"userId": user.Id, "idempotencyKey": uuid.NewString()}}, &placed)
+
+	// Assert
+	assert.Empty(t, errs)
+	assert.Equal(t, "PAID", placed.Checkout.Order.Status)
+	assert.Equal(t, 60.0, placed.Checkout.Order.Amount)
+	assert.False(t, placed.Checkout.Replayed)
+
+	var data struct {
+		User struct {
+			Orders []struct {
+				ID    string
+				Items []struct {
+					Product struct {
+						Seller struct{ Name string }
+					}
+				}
+			}
+		}
+	}
+	errs = execute(t, executor, user.Id, `query($id: ID!) { user(id: $id) { orders { id items { product { seller { name } } } } } }`,
+		map[string]interface{}{"id": user.Id}, &data)
+	assert.Empty(t, errs)
+	assert.Len(t, data.User.Orders, 1)
+	assert.Equal(t, placed.Checkout.Order.ID, data.User.Orders[0].ID)
+	assert.Len(t, data.User.Orders[0].Items, len(products))
+	assert.Equal(t, "Seller", data.User.Orders[0].Items[0].Product.Seller.Name)
+}
+
+// Test missing resources resolve to null and engine errors carry their code
+func TestErrors(t *testing.T) {
+	executor := NewExecutor(internal.NewEngine(nil))
+
+	// Act
+	var data struct {
+		Product  *struct{ ID string }
+		Products []*struct{ ID string }
+	}
+	lookupErrs := execute(t, executor, "", `{ product(id: "missing") { id } products(ids: ["missing"]) { id } }`, nil, &data)
+	mutationErrs := execute(t, executor, "missing", `mutation { addToCart(userId: "missing", productId: "missing", quantity: 1) { total } }`, nil, nil)
+
+	// Assert
+	assert.Empty(t, lookupErrs)
+	assert.Nil(t, data.Product)
+	assert.Equal(t, []*struct{ ID string }{nil}, data.Products)
+	assert.Len(t, mutationErrs, 1)
+	assert.ErrorIs(t, mutationErrs[0], internal.ErrNotFound)
+	assert.Equal(t, "not_found", mutationErrs[0].Extensions["code"])
+}
+
+// Test order and cart resolvers only act for the caller
+func TestResolvers_RequireCaller(t *testing.T) {
+	svc := internal.NewEngine(nil)
+	user, products := createUserWithCart(t, svc)
+	executor := NewExecutor(svc)
+	variables := map[string]interface{}{"userId": user.Id, "productId": products[0].Id}
+	queries := map[string]string{
+		"order":     `query($userId: ID!) { order(userId: $userId, id: "missing") { id } }`,
+		"addToCart": `mutation($userId: ID!, $productId: ID!) { addToCart(userId: $userId, productId: $productId, quantity: 1) { total } }`,
+		"checkout":  `mutation($userId: ID!) { checkout(input: {userId: $userId}) { replayed } }`,
+	}
+
+	for name, query := range queries {
+		// Act
+		missingErrs := execute(t, executor, "", query, variables, nil)
+		otherErrs := execute(t, executor, "someone-else", query, variables, nil)
+
+		// Assert
+		assert.Len(t, missingErrs, 1, name)
+		assert.Equal(t, "unauthorized", missingErrs[0].Extensions["code"], name)
+		assert.Len(t, otherErrs, 1, name)
+		assert.Equal(t, "forbidden", otherErrs[0].Extensions["code"], name)
+	}
+	orders, err := svc.GetOrders(context.Background(), user.Id)
+	assert.NoError(t, err)
+	assert.Empty(t, orders)
+}
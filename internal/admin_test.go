@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"context"
 	"testing"
+	"time"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,40 +12,40 @@ func TestAnalytics(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("ken", "ken@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "ken", "ken@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 2", "Description of product 2", 20, seller.Id, 199.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 20, seller.Id, 199.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p2)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	_, err = shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.NoError(t, err)
@@ -51,20 +53,20 @@ func TestAnalytics(t *testing.T) {
 
 	amount1 := 99.99*5
 
-	_, err = shoppingApp.AddToCart(user.Id, p2.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p2.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	coupon, err := shoppingApp.GetDiscountCoupon(user.Id)
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotEmpty(t, coupon)
 
 	// Act
-	order, err = shoppingApp.Checkout(user.Id, coupon)
+	order, err = shoppingApp.Checkout(context.Background(), user.Id, coupon)
 
 	discount := 199.99*5*0.10
 	amount2 := 199.99*5 - discount
@@ -78,5 +80,207 @@ func TestAnalytics(t *testing.T) {
 	assert.Equal(t, 10, totalItems)
 	assert.Equal(t, finalAmount, totalAmount)
 	assert.Equal(t, discount, totalDiscount)
-	assert.Equal(t, []string{coupon}, coupons)
-}
\ No newline at end of file
+	assert.Equal(t, 1, coupons)
+	assert.Equal(t, CouponPolicyEveryNthOrder, order.CouponPolicy)
+}
+
+// Helper function to add a paid order at the given time to the order book
+func addOrderAt(shoppingApp *shoppingEngine, createdAt time.Time, amount float64, quantity int) *order {
+	items := []*lineItem{{ProductId: "p1", UnitPrice: amount / float64(quantity), Quantity: quantity, LineTotal: amount}}
+	order := newOrder(generateUUID(), "user", items, amount, "", 0, amount, createdAt)
+	order.Status = OrderStatusPaid
+	shoppingApp.OrderBook.Orders[order.Id] = order
+	return order
+}
+
+// Test daily buckets in a non-UTC timezone
+func TestGetTimeseries_DailyBuckets(t *testing.T) {
+	shoppingApp := createMockEngine()
+	location := time.FixedZone("UTC+5:30", 5*3600+1800)
+
+	// 20:00 UTC on Jan 1st is already Jan 2nd in UTC+5:30
+	addOrderAt(shoppingApp, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), 100, 1)
+	addOrderAt(shoppingApp, time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), 50, 2)
+	addOrderAt(shoppingApp, time.Date(2024, 1, 1, 21, 0, 0, 0, time.UTC), 150, 3)
+	cancelled := addOrderAt(shoppingApp, time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), 999, 1)
+	cancelled.Status = OrderStatusCancelled
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, location)
+	to := time.Date(2024, 1, 4, 0, 0, 0, 0, location)
+
+	// Act
+	buckets, err := shoppingApp.OrderBook.GetTimeseries(from, to, BucketDay, location)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, buckets, 3)
+	assert.Equal(t, from, buckets[0].Start)
+	assert.Equal(t, 1, buckets[0].Orders)
+	assert.Equal(t, 100.0, buckets[0].Revenue)
+	assert.Equal(t, 2, buckets[1].Orders)
+	assert.Equal(t, 5, buckets[1].Items)
+	assert.Equal(t, 200.0, buckets[1].Revenue)
+	assert.Equal(t, 100.0, buckets[1].AverageOrderValue)
+	assert.Equal(t, 0, buckets[2].Orders) // Empty days are still reported
+}
+
+// Test weekly and monthly bucket boundaries
+func TestGetTimeseries_WeekAndMonth(t *testing.T) {
+	shoppingApp := createMockEngine()
+	addOrderAt(shoppingApp, time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), 10, 1) // Wednesday
+	addOrderAt(shoppingApp, time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC), 20, 1)  // Sunday, same week
+	addOrderAt(shoppingApp, time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC), 30, 1)  // Monday, next week
+
+	from := time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC)
+
+	// Act
+	weeks, err := shoppingApp.OrderBook.GetTimeseries(from, to, BucketWeek, time.UTC)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, weeks, 2)
+	assert.Equal(t, 30.0, weeks[0].Revenue)
+	assert.Equal(t, 30.0, weeks[1].Revenue)
+
+	// Act
+	months, err := shoppingApp.OrderBook.GetTimeseries(from, to, BucketMonth, time.UTC)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, months, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), months[0].Start)
+	assert.Equal(t, 10.0, months[0].Revenue)
+	assert.Equal(t, 50.0, months[1].Revenue)
+}
+
+// Test invalid timeseries parameters
+func TestGetTimeseries_Invalid(t *testing.T) {
+	shoppingApp := createMockEngine()
+	now := time.Now()
+
+	// Act
+	_, err := shoppingApp.OrderBook.GetTimeseries(now, now.Add(time.Hour), "year", time.UTC)
+
+	// Assert
+	assert.Error(t, err)
+
+	// Act
+	_, err = shoppingApp.OrderBook.GetTimeseries(now, now.Add(-time.Hour), BucketDay, time.UTC)
+
+	// Assert
+	assert.Error(t, err)
+
+	// Act
+	_, err = shoppingApp.OrderBook.GetTimeseries(now.AddDate(-10, 0, 0), now, BucketHour, time.UTC)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// Test seller analytics only include the seller's products
+func TestGetSellerAnalytics(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	other, err := shoppingApp.RegisterUser(context.Background(), "Other seller", "other@example.com")
+	assert.NoError(t, err)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10)
+	assert.NoError(t, err)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 3, seller.Id, 20)
+	assert.NoError(t, err)
+	p3, err := shoppingApp.RegisterProduct(context.Background(), "Product 3", "Description of product 3", 10, other.Id, 30)
+	assert.NoError(t, err)
+
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+
+	// First order is kept
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 4)
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p3.Id, 1)
+	assert.NoError(t, err)
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+
+	// Second order is cancelled
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p2.Id, 1)
+	assert.NoError(t, err)
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, order.Id)
+	assert.NoError(t, err)
+
+	// Act
+	analytics, err := shoppingApp.GetSellerAnalytics(context.Background(), seller.Id, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 4, analytics.UnitsSold)
+	assert.Equal(t, 40.0, analytics.Revenue)
+	assert.Equal(t, 1, analytics.UnitsReturned)
+	assert.Equal(t, 20.0, analytics.ReturnedRevenue)
+	assert.Len(t, analytics.Products, 2)
+	assert.Equal(t, []string{p2.Id}, analytics.LowStockProducts)
+
+	// Act
+	_, err = shoppingApp.GetSellerAnalytics(context.Background(), "nonExistentSeller", 5)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// Test ranked product, customer and coupon reports
+func TestReports(t *testing.T) {
+	shoppingApp := createMockEngine()
+	now := time.Now()
+
+	first := addOrderAt(shoppingApp, now.Add(-time.Hour), 100, 1)
+	first.UserId = "alice"
+	first.Items = []*lineItem{{ProductId: "p1", Name: "Product 1", Quantity: 1, LineTotal: 100}}
+
+	second := addOrderAt(shoppingApp, now.Add(-time.Hour), 90, 5)
+	second.UserId = "bob"
+	second.DiscountCoupon = "SAVE10"
+	second.CouponPolicy = CouponPolicyFirstOrder
+	second.Discount = 10
+	second.Items = []*lineItem{{ProductId: "p2", Name: "Product 2", Quantity: 5, LineTotal: 100, Discount: 10}}
+
+	old := addOrderAt(shoppingApp, now.AddDate(0, -2, 0), 500, 1)
+	old.UserId = "bob"
+
+	from := now.AddDate(0, -1, 0)
+
+	// Act
+	byUnits, err := shoppingApp.OrderBook.GetTopProducts(from, now, 10, RankByUnits)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, byUnits, 2)
+	assert.Equal(t, "p2", byUnits[0].ProductId)
+	assert.Equal(t, 90.0, byUnits[0].Revenue)
+
+	// Act
+	byRevenue, err := shoppingApp.OrderBook.GetTopProducts(from, now, 1, RankByRevenue)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, byRevenue, 1)
+	assert.Equal(t, "p1", byRevenue[0].ProductId)
+
+	// Act
+	customers := shoppingApp.OrderBook.GetTopCustomers(from, now, 10)
+
+	// Assert
+	assert.Len(t, customers, 2)
+	assert.Equal(t, "alice", customers[0].UserId)
+	assert.Equal(t, 90.0, customers[1].Spend) // Old order is outside the range
+
+	// Act
+	coupons := shoppingApp.OrderBook.GetCouponReport(from, now, 10)
+
+	// Assert
+	incremental := -10.0 // The coupon order brought 90 against 100 for the order without a coupon
+	assert.Equal(t, []*couponStats{{Policy: CouponPolicyFirstOrder, Redemptions: 1, DiscountGiven: 10, Revenue: 90, BaselineOrderValue: 100, IncrementalRevenue: &incremental}}, coupons)
+}
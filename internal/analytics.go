@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Bucket sizes supported by GetTimeseries
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// maxBuckets limits the size of a timeseries response
+const maxBuckets = 10000
+
+// analyticsBucket holds the sales figures of one time bucket
+type analyticsBucket struct {
+	Start               time.Time   `json:"start"`                 // Start of the bucket in the requested timezone
+	Revenue             float64     `json:"revenue"`               // Sum of amounts paid
+	Orders              int         `json:"orders"`                // Number of orders placed
+	Items               int         `json:"items"`                 // Number of items sold
+	AverageOrderValue   float64     `json:"average_order_value"`   // Revenue / Orders
+	Discount            float64     `json:"discount"`              // Sum of coupon and points discounts
+}
+
+// bucketStart truncates the time to the start of its bucket in the given location
+func bucketStart(t time.Time, interval string, location *time.Location) time.Time {
+	t = t.In(location)
+	year, month, day := t.Date()
+	switch interval {
+	case BucketHour:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, location)
+	case BucketWeek:
+		// Weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, location)
+	case BucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// nextBucket returns the start of the bucket following the given one
+func nextBucket(start time.Time, interval string) time.Time {
+	year, month, day := start.Date()
+	switch interval {
+	case BucketHour:
+		// Built from the wall clock so DST changes don't shift bucket boundaries
+		return time.Date(year, month, day, start.Hour()+1, 0, 0, 0, start.Location())
+	case BucketWeek:
+		return time.Date(year, month, day+7, 0, 0, 0, 0, start.Location())
+	case BucketMonth:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, start.Location())
+	}
+	return time.Date(year, month, day+1, 0, 0, 0, 0, start.Location())
+}
+
+// GetTimeseries aggregates orders placed in [from, to) into buckets of the given interval
+func (o *orderBook) GetTimeseries(from time.Time, to time.Time, interval string, location *time.Location) ([]*analyticsBucket, error) {
+	if interval != BucketHour && interval != BucketDay && interval != BucketWeek && interval != BucketMonth {
+		return nil, newError(ErrValidation, "Unknown interval %q", interval)
+	}
+	if !from.Before(to) {
+		return nil, newError(ErrValidation, "Start of the date range must be before its end")
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	// Create every bucket in the range so that gaps show up as zeros
+	var buckets []*analyticsBucket
+	index := make(map[time.Time]*analyticsBucket)
+	for start := bucketStart(from, interval, location); start.Before(to); start = nextBucket(start, interval) {
+		if len(buckets) == maxBuckets {
+			return nil, newError(ErrValidation, "Date range is too large for the %s interval", interval)
+		}
+		bucket := &analyticsBucket{Start: start}
+		buckets = append(buckets, bucket)
+		index[start.UTC()] = bucket
+	}
+
+	o.OrderMutex.Lock()
+	defer o.OrderMutex.Unlock()
+
+	for _, order := range o.Orders {
+		if !order.isActive() || order.CreatedAt.Before(from) || !order.CreatedAt.Before(to) {
+			continue
+		}
+		bucket := index[bucketStart(order.CreatedAt, interval, location).UTC()]
+		if bucket == nil {
+			continue
+		}
+		bucket.Orders++
+		bucket.Revenue += order.AmountToPay
+		bucket.Discount += order.Discount + order.PointsDiscount
+		for _, item := range order.Items {
+			bucket.Items += item.Quantity
+		}
+	}
+
+	for _, bucket := range buckets {
+		if bucket.Orders > 0 {
+			bucket.AverageOrderValue = bucket.Revenue / float64(bucket.Orders)
+		}
+	}
+	return buckets, nil
+}
+
+// productPerformance holds the sales figures of one of a seller's products
+type productPerformance struct {
+	ProductId         string    `json:"product_id"`          // Product ID
+	Name              string    `json:"name"`                // Current name, or the name it was last sold under
+	UnitsSold         int       `json:"units_sold"`          // Units in orders that still stand
+	Revenue           float64   `json:"revenue"`             // Amount paid for those units after discounts
+	UnitsReturned     int       `json:"units_returned"`      // Units in cancelled or refunded orders
+	ReturnedRevenue   float64   `json:"returned_revenue"`    // Amount given back for returned units
+	Stock             int       `json:"stock"`               // Units currently in stock
+	LowStock          bool      `json:"low_stock"`           // Whether the stock is at or below the threshold
+}
+
+// sellerAnalytics holds the sales figures of a seller
+type sellerAnalytics struct {
+	SellerId          string                  `json:"seller_id"`          // Seller ID
+	UnitsSold         int                     `json:"units_sold"`         // Units sold over all products
+	Revenue           float64                 `json:"revenue"`            // Revenue over all products
+	UnitsReturned     int                     `json:"units_returned"`     // Units returned over all products
+	ReturnedRevenue   float64                 `json:"returned_revenue"`   // Revenue given back over all products
+	Products          []*productPerformance   `json:"products"`           // Figures per product
+	LowStockProducts  []string                `json:"low_stock_products"` // IDs of products at or below the threshold
+}
+
+// GetSellerAnalytics computes the sales figures of a seller from the orders that include their products
+func (s *shoppingEngine) GetSellerAnalytics(ctx context.Context, sellerId string, lowStockThreshold int) (*sellerAnalytics, error) {
+	// Check if seller exists
+	_, err := s.GetUser(ctx, sellerId)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &sellerAnalytics{SellerId: sellerId, Products: []*productPerformance{}, LowStockProducts: []string{}}
+	byProduct := make(map[string]*productPerformance)
+	for _, product := range s.Inventory.ProductsBySeller[sellerId] {
+		performance := &productPerformance{
+			ProductId: product.Id,
+			Name:      product.Name,
+			Stock:     product.Quantity,
+			LowStock:  product.Quantity <= lowStockThreshold,
+		}
+		byProduct[product.Id] = performance
+		analytics.Products = append(analytics.Products, performance)
+		if performance.LowStock {
+			analytics.LowStockProducts = append(analytics.LowStockProducts, product.Id)
+		}
+	}
+
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	for _, order := range s.OrderBook.Orders {
+		returned := order.Status == OrderStatusCancelled || order.Status == OrderStatusRefunded
+		if !order.isActive() && !returned {
+			continue
+		}
+		for _, item := range order.Items {
+			if item.SellerId != sellerId {
+				continue
+			}
+			performance := byProduct[item.ProductId]
+			if performance == nil {
+				// Product is no longer listed, report it under the name it was sold with
+				performance = &productPerformance{ProductId: item.ProductId, Name: item.Name}
+				byProduct[item.ProductId] = performance
+				analytics.Products = append(analytics.Products, performance)
+			}
+			paid := item.LineTotal - item.Discount
+			if returned {
+				performance.UnitsReturned += item.Quantity
+				performance.ReturnedRevenue += paid
+				analytics.UnitsReturned += item.Quantity
+				analytics.ReturnedRevenue += paid
+			} else {
+				performance.UnitsSold += item.Quantity
+				performance.Revenue += paid
+				analytics.UnitsSold += item.Quantity
+				analytics.Revenue += paid
+			}
+		}
+	}
+	return analytics, nil
+}
+
+// Rankings supported by GetTopProducts
+const (
+	RankByUnits   = "units"
+	RankByRevenue = "revenue"
+)
+
+// Policy reported for coupons applied before orders recorded their coupon policy
+const couponPolicyUnknown = "unknown"
+
+// couponStats holds the usage of the coupons handed out by a coupon policy
+type couponStats struct {
+	Policy               string     `json:"policy"`                          // Coupon policy in effect when the coupons were applied
+	Redemptions          int        `json:"redemptions"`                     // Number of orders that applied a coupon
+	DiscountGiven        float64    `json:"discount_given"`                  // Total discount granted by the coupons
+	Revenue              float64    `json:"revenue"`                         // Amount paid on orders that applied a coupon
+	BaselineOrderValue   float64    `json:"baseline_order_value"`            // Average amount paid on orders without a coupon in the range
+	IncrementalRevenue   *float64   `json:"incremental_revenue,omitempty"`   // Revenue above what as many orders without a coupon bring, omitted without such orders
+}
+
+// productRanking is an entry of the best-selling products report
+type productRanking struct {
+	ProductId   string    `json:"product_id"`   // Product ID
+	Name        string    `json:"name"`         // Name the product was last sold under
+	SellerId    string    `json:"seller_id"`    // Seller of the product
+	UnitsSold   int       `json:"units_sold"`   // Units sold in the range
+	Revenue     float64   `json:"revenue"`      // Amount paid for those units after discounts
+}
+
+// customerRanking is an entry of the top customers report
+type customerRanking struct {
+	UserId   string    `json:"user_id"`   // Customer ID
+	Orders   int       `json:"orders"`    // Orders placed in the range
+	Spend    float64   `json:"spend"`     // Amount paid in the range
+}
+
+// GetTopProducts ranks products sold in [from, to) by units or revenue
+func (o *orderBook) GetTopProducts(from time.Time, to time.Time, limit int, by string) ([]*productRanking, error) {
+	if by != RankByUnits && by != RankByRevenue {
+		return nil, newError(ErrValidation, "Unknown ranking %q", by)
+	}
+
+	rankings := make(map[string]*productRanking)
+	o.forEachOrder(from, to, func(order *order) {
+		for _, item := range order.Items {
+			ranking := rankings[item.ProductId]
+			if ranking == nil {
+				ranking = &productRanking{ProductId: item.ProductId}
+				rankings[item.ProductId] = ranking
+			}
+			ranking.Name = item.Name
+			ranking.SellerId = item.SellerId
+			ranking.UnitsSold += item.Quantity
+			ranking.Revenue += item.LineTotal - item.Discount
+		}
+	})
+
+	result := make([]*productRanking, 0, len(rankings))
+	for _, ranking := range rankings {
+		result = append(result, ranking)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if by == RankByUnits && a.UnitsSold != b.UnitsSold {
+			return a.UnitsSold > b.UnitsSold
+		}
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.ProductId < b.ProductId
+	})
+	return truncate(result, limit), nil
+}
+
+// GetTopCustomers ranks customers by the amount paid in [from, to)
+func (o *orderBook) GetTopCustomers(from time.Time, to time.Time, limit int) []*customerRanking {
+	rankings := make(map[string]*customerRanking)
+	o.forEachOrder(from, to, func(order *order) {
+		ranking := rankings[order.UserId]
+		if ranking == nil {
+			ranking = &customerRanking{UserId: order.UserId}
+			rankings[order.UserId] = ranking
+		}
+		ranking.Orders++
+		ranking.Spend += order.AmountToPay
+	})
+
+	result := make([]*customerRanking, 0, len(rankings))
+	for _, ranking := range rankings {
+		result = append(result, ranking)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Spend != result[j].Spend {
+			return result[i].Spend > result[j].Spend
+		}
+		return result[i].UserId < result[j].UserId
+	})
+	return truncate(result, limit)
+}
+
+// GetCouponReport ranks the coupon policies of coupons used in [from, to) by the revenue of the orders they were applied to.
+// Incremental revenue compares those orders with the average order without a coupon in the same range.
+func (o *orderBook) GetCouponReport(from time.Time, to time.Time, limit int) []*couponStats {
+	report := make(map[string]*couponStats)
+	var baselineOrders int
+	var baselineRevenue float64
+	o.forEachOrder(from, to, func(order *order) {
+		if order.DiscountCoupon == "" {
+			baselineOrders++
+			baselineRevenue += order.AmountToPay
+			return
+		}
+		policy := order.CouponPolicy
+		if policy == "" {
+			policy = couponPolicyUnknown
+		}
+		stats := report[policy]
+		if stats == nil {
+			stats = &couponStats{Policy: policy}
+			report[policy] = stats
+		}
+		stats.Redemptions++
+		stats.DiscountGiven += order.Discount
+		stats.Revenue += order.AmountToPay
+	})
+
+	result := make([]*couponStats, 0, len(report))
+	for _, stats := range report {
+		if baselineOrders > 0 {
+			stats.BaselineOrderValue = baselineRevenue / float64(baselineOrders)
+			incremental := stats.Revenue - float64(stats.Redemptions)*stats.BaselineOrderValue
+			stats.IncrementalRevenue = &incremental
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Revenue != result[j].Revenue {
+			return result[i].Revenue > result[j].Revenue
+		}
+		return result[i].Policy < result[j].Policy
+	})
+	return truncate(result, limit)
+}
+
+// forEachOrder calls fn for every active order placed in [from, to)
+func (o *orderBook) forEachOrder(from time.Time, to time.Time, fn func(order *order)) {
+	o.OrderMutex.Lock()
+	defer o.OrderMutex.Unlock()
+
+	for _, order := range o.Orders {
+		if order.isActive() && !order.CreatedAt.Before(from) && order.CreatedAt.Before(to) {
+			fn(order)
+		}
+	}
+}
+
+// truncate returns at most limit entries, all of them if limit isn't positive
+func truncate[T any](entries []T, limit int) []T {
+	if limit > 0 && len(entries) > limit {
+		return entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrAuditChainBroken is returned when an audit entry doesn't match its hash or its predecessor
+var ErrAuditChainBroken = errors.New("Audit log has been tampered with")
+
+// Audited actions
+const (
+	AuditProductRegistered   = "product.registered"
+	AuditProductCategorySet  = "product.category_set"
+	AuditProductRemoved      = "product.removed"
+	AuditProductWatched      = "product.watched"
+	AuditProductUnwatched    = "product.unwatched"
+	AuditUserRemoved         = "user.removed"
+	AuditStoreCreditIssued   = "wallet.store_credit_issued"
+	AuditCouponPolicyChanged = "coupon_policy.changed"
+	AuditOrderStatusChanged  = "order.status_changed"
+	AuditLogLevelChanged     = "log_level.changed"
+)
+
+// Actor recorded when an action isn't attributed to anyone
+const systemActor = "system"
+
+// AdminTokenActor is recorded for requests authorised by the admin token that don't name a user
+const AdminTokenActor = "admin-token"
+
+// actorKey is the context key of the user performing an action
+type actorKey struct{}
+
+// actor is the user performing an action and whether they were only named by the caller
+type actor struct {
+	id      string
+	claimed bool
+}
+
+// WithActor returns a copy of ctx attributing the actions performed with it to the actor, whose identity was verified
+func WithActor(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor{id: id})
+}
+
+// WithClaimedActor returns a copy of ctx attributing the actions performed with it to an actor the caller named
+// without proving it, such as the X-User-Id header. The audit entries mark the actor as claimed.
+func WithClaimedActor(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor{id: id, claimed: true})
+}
+
+// actorFrom returns the actor carried by ctx, or the system actor
+func actorFrom(ctx context.Context) actor {
+	if found, ok := ctx.Value(actorKey{}).(actor); ok && found.id != "" {
+		return found
+	}
+	return actor{id: systemActor}
+}
+
+// auditEntry records a privileged action, chained to the previous entry by its hash
+type auditEntry struct {
+	Sequence     int               `json:"sequence"`          // Position of the entry in the log, starting at 1
+	Timestamp    time.Time         `json:"timestamp"`         // Time the action was performed
+	Actor        string            `json:"actor"`             // User who performed the action
+	ActorClaimed bool              `json:"actor_claimed,omitempty"` // Actor was named by the caller without being authenticated
+	Action       string            `json:"action"`            // One of the Audit* actions
+	Target       string            `json:"target"`            // ID of the affected resource
+	Before       json.RawMessage   `json:"before,omitempty"`  // State of the resource before the action
+	After        json.RawMessage   `json:"after,omitempty"`   // State of the resource after the action
+	PrevHash     string            `json:"prev_hash"`         // Hash of the previous entry
+	Hash         string            `json:"hash"`              // Hash of this entry including PrevHash
+}
+
+// computeHash hashes every field of the entry except the hash itself
+func (e *auditEntry) computeHash() string {
+	unhashed := *e
+	unhashed.Hash = ""
+	payload, _ := json.Marshal(unhashed)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditHead identifies the last entry of the audit log. The hash chain alone can't show entries removed from the end,
+// so every new head is also logged, and only the logged heads can be trusted to catch a rewritten state file.
+type AuditHead struct {
+	Sequence int    `json:"sequence"` // Sequence of the last entry, 0 for an empty log
+	Hash     string `json:"hash"`     // Hash of the last entry
+}
+
+// AuditFilter selects audit entries, zero fields match everything
+type AuditFilter struct {
+	Actor     string      // Only entries by this actor
+	Action    string      // Only entries with this action
+	Target    string      // Only entries about this resource
+	From      time.Time   // Only entries at or after this time
+	To        time.Time   // Only entries before this time
+	Limit     int         // Maximum number of entries, keeping the most recent
+}
+
+// matches reports whether the entry is selected by the filter
+func (f AuditFilter) matches(entry *auditEntry) bool {
+	return (f.Actor == "" || entry.Actor == f.Actor) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Target == "" || entry.Target == f.Target) &&
+		(f.From.IsZero() || !entry.Timestamp.Before(f.From)) &&
+		(f.To.IsZero() || entry.Timestamp.Before(f.To))
+}
+
+// auditLog is an append-only, hash-chained log of privileged actions
+type auditLog struct {
+	Entries   []*auditEntry   // Entries in the order they were recorded
+	Anchor    AuditHead       // Head the log is known to have reached, the log must still contain it
+	Mutex     sync.Mutex      // Mutex to keep the chain consistent
+}
+
+func newAuditLog() *auditLog {
+	return &auditLog{}
+}
+
+// record appends an entry for the action, chaining it to the last entry
+func (a *auditLog) record(by actor, action string, target string, before interface{}, after interface{}, now time.Time) *auditEntry {
+	a.Mutex.Lock()
+	defer a.Mutex.Unlock()
+
+	entry := &auditEntry{
+		Sequence:  len(a.Entries) + 1,
+		Timestamp: now.UTC(),
+		Actor:        by.id,
+		ActorClaimed: by.claimed,
+		Action:       action,
+		Target:    target,
+		Before:    marshalAuditState(before),
+		After:     marshalAuditState(after),
+	}
+	if len(a.Entries) > 0 {
+		entry.PrevHash = a.Entries[len(a.Entries)-1].Hash
+	}
+	entry.Hash = entry.computeHash()
+	a.Entries = append(a.Entries, entry)
+	return entry
+}
+
+// marshalAuditState snapshots a value so later changes to it don't alter the entry
+func marshalAuditState(state interface{}) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		payload, _ = json.Marshal(fmt.Sprintf("%v", state))
+	}
+	return payload
+}
+
+// query returns copies of the entries selected by the filter in the order they were recorded
+func (a *auditLog) query(filter AuditFilter) []auditEntry {
+	a.Mutex.Lock()
+	defer a.Mutex.Unlock()
+
+	entries := []auditEntry{}
+	for _, entry := range a.Entries {
+		if filter.matches(entry) {
+			entries = append(entries, *entry)
+		}
+	}
+	// Keep the most recent entries
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries
+}
+
+// verify recomputes the chain, returning the sequence of the first entry that doesn't match
+func (a *auditLog) verify() error {
+	a.Mutex.Lock()
+	defer a.Mutex.Unlock()
+
+	var prevHash string
+	for i, entry := range a.Entries {
+		if entry.Sequence != i+1 || entry.PrevHash != prevHash || entry.Hash != entry.computeHash() {
+			return fmt.Errorf("%w at entry %d", ErrAuditChainBroken, i+1)
+		}
+		prevHash = entry.Hash
+	}
+	// Entries removed from the end leave an intact chain, but not the anchored head
+	if a.Anchor.Sequence > 0 {
+		if len(a.Entries) < a.Anchor.Sequence {
+			return fmt.Errorf("%w, it ends at entry %d before the anchored entry %d", ErrAuditChainBroken, len(a.Entries), a.Anchor.Sequence)
+		}
+		if a.Entries[a.Anchor.Sequence-1].Hash != a.Anchor.Hash {
+			return fmt.Errorf("%w at anchored entry %d", ErrAuditChainBroken, a.Anchor.Sequence)
+		}
+	}
+	return nil
+}
+
+// head returns the sequence and hash of the last entry
+func (a *auditLog) head() AuditHead {
+	a.Mutex.Lock()
+	defer a.Mutex.Unlock()
+
+	if len(a.Entries) == 0 {
+		return AuditHead{}
+	}
+	last := a.Entries[len(a.Entries)-1]
+	return AuditHead{Sequence: last.Sequence, Hash: last.Hash}
+}
+
+// audit records a privileged action performed by the actor carried by ctx
+func (s *shoppingEngine) audit(ctx context.Context, action string, target string, before interface{}, after interface{}) {
+	entry := s.Audit.record(actorFrom(ctx), action, target, before, after, s.clock())
+	// The logs keep every head outside the state file, to compare the saved log against
+	s.logger(ctx).Sugar().Infof("Audit entry %d: %s on %s by %s, audit log head is %s", entry.Sequence, action, target, entry.Actor, entry.Hash)
+}
+
+// RecordAudit records a privileged action performed outside the engine
+func (s *shoppingEngine) RecordAudit(ctx context.Context, action string, target string, before interface{}, after interface{}) {
+	s.audit(ctx, action, target, before, after)
+}
+
+// GetAuditLog returns the audit entries selected by the filter, oldest first
+func (s *shoppingEngine) GetAuditLog(ctx context.Context, filter AuditFilter) []auditEntry {
+	return s.Audit.query(filter)
+}
+
+// VerifyAuditLog checks the hash chain and the head saved with the state. It catches entries altered, reordered or
+// removed by hand, not a state file rewritten with a recomputed chain, which only the logged heads reveal.
+func (s *shoppingEngine) VerifyAuditLog(ctx context.Context) error {
+	return s.Audit.verify()
+}
+
+// GetAuditHead returns the last entry of the audit log, to compare with the heads in the logs
+func (s *shoppingEngine) GetAuditHead(ctx context.Context) AuditHead {
+	return s.Audit.head()
+}
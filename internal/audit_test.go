@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Test privileged actions are recorded with their actor and chained together
+func TestAuditLog_RecordsActions(t *testing.T) {
+	shoppingApp := createMockEngine()
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	ctx := WithActor(context.Background(), seller.Id)
+
+	// Act
+	p1, err := shoppingApp.RegisterProduct(ctx, "Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	assert.NoError(t, err)
+	_, err = shoppingApp.SetProductCategory(ctx, p1.Id, "books")
+	assert.NoError(t, err)
+	err = shoppingApp.RemoveUser(context.Background(), seller.Id)
+	assert.NoError(t, err)
+
+	// Assert
+	entries := shoppingApp.GetAuditLog(context.Background(), AuditFilter{})
+	assert.Len(t, entries, 3)
+	assert.Equal(t, AuditProductRegistered, entries[0].Action)
+	assert.Equal(t, seller.Id, entries[0].Actor)
+	assert.False(t, entries[0].ActorClaimed)
+	assert.Equal(t, p1.Id, entries[0].Target)
+	assert.Empty(t, entries[0].PrevHash)
+	assert.JSONEq(t, `{"category":""}`, string(entries[1].Before))
+	assert.JSONEq(t, `{"category":"books"}`, string(entries[1].After))
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, systemActor, entries[2].Actor)
+	assert.NoError(t, shoppingApp.VerifyAuditLog(context.Background()))
+
+	// Snapshots aren't affected by later changes
+	var registered product
+	assert.NoError(t, json.Unmarshal(entries[0].After, &registered))
+	assert.Equal(t, "", registered.Category)
+}
+
+// Test the audit log can be filtered by actor, action and target
+func TestAuditLog_Filter(t *testing.T) {
+	shoppingApp := createMockEngine()
+	shoppingApp.RecordAudit(WithActor(context.Background(), "admin"), AuditLogLevelChanged, "logger", nil, nil)
+	shoppingApp.RecordAudit(WithActor(context.Background(), "admin"), AuditCouponPolicyChanged, "coupon_policy", nil, nil)
+	shoppingApp.RecordAudit(WithActor(context.Background(), "other"), AuditLogLevelChanged, "logger", nil, nil)
+
+	// Assert
+	assert.Len(t, shoppingApp.GetAuditLog(context.Background(), AuditFilter{Actor: "admin"}), 2)
+	assert.Len(t, shoppingApp.GetAuditLog(context.Background(), AuditFilter{Action: AuditLogLevelChanged}), 2)
+	assert.Len(t, shoppingApp.GetAuditLog(context.Background(), AuditFilter{Target: "coupon_policy"}), 1)
+	latest := shoppingApp.GetAuditLog(context.Background(), AuditFilter{Limit: 1})
+	assert.Len(t, latest, 1)
+	assert.Equal(t, 3, latest[0].Sequence)
+}
+
+// Test actors named by the caller are marked as claimed
+func TestAuditLog_ClaimedActor(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	// Act
+	shoppingApp.RecordAudit(WithClaimedActor(context.Background(), "user-1"), AuditLogLevelChanged, "logger", nil, nil)
+	shoppingApp.RecordAudit(context.Background(), AuditLogLevelChanged, "logger", nil, nil)
+
+	// Assert
+	entries := shoppingApp.GetAuditLog(context.Background(), AuditFilter{})
+	assert.Equal(t, "user-1", entries[0].Actor)
+	assert.True(t, entries[0].ActorClaimed)
+	assert.Equal(t, systemActor, entries[1].Actor)
+	assert.False(t, entries[1].ActorClaimed)
+	assert.NoError(t, shoppingApp.VerifyAuditLog(context.Background()))
+}
+
+// Test altering or removing an entry breaks the chain
+func TestAuditLog_DetectsTampering(t *testing.T) {
+	for name, tamper := range map[string]func(log *auditLog){
+		"altered": func(log *auditLog) { log.Entries[1].Actor = "someone-else" },
+		"removed": func(log *auditLog) { log.Entries = append(log.Entries[:1], log.Entries[2:]...) },
+		"rehashed": func(log *auditLog) {
+			log.Entries[1].Target = "other"
+			log.Entries[1].Hash = log.Entries[1].computeHash()
+		},
+	} {
+		shoppingApp := createMockEngine()
+		for _, target := range []string{"a", "b", "c"} {
+			shoppingApp.RecordAudit(context.Background(), AuditProductWatched, target, nil, nil)
+		}
+
+		// Act
+		tamper(shoppingApp.Audit)
+		err := shoppingApp.VerifyAuditLog(context.Background())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrAuditChainBroken), name)
+	}
+}
+
+// Test every new head of the audit log is logged, outside the state file
+func TestAuditLog_LogsHead(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	shoppingApp := createMockEngine(WithDefaultLogger(zap.New(core)))
+
+	// Act
+	shoppingApp.RecordAudit(context.Background(), AuditProductWatched, "a", nil, nil)
+	shoppingApp.RecordAudit(context.Background(), AuditProductWatched, "b", nil, nil)
+
+	// Assert
+	for _, entry := range shoppingApp.Audit.Entries {
+		assert.Equal(t, 1, logs.FilterMessageSnippet(entry.Hash).Len())
+	}
+}
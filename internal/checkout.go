@@ -1,19 +1,21 @@
 package internal
 
 import (
-	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"context"
+	"github.com/ecommerce-store/utilities"
 )
 
 // AddToCart adds a product to the user's cart
-func (s *shoppingEngine) AddToCart(userId string, productId string, quantity int) (map[string]int, error) {
+func (s *shoppingEngine) AddToCart(ctx context.Context, userId string, productId string, quantity int) (map[string]int, error) {
 	// Check if user exists
-	_, err := s.GetUser(userId)
+	_, err := s.GetUser(ctx, userId)
 	if err != nil {
 		return nil, err
 	}
 
 	// check if product exists
-	_, err = s.GetProduct(productId)
+	_, err = s.GetProduct(ctx, productId)
 	if err != nil {
 		return nil, err
 	}
@@ -21,13 +23,13 @@ func (s *shoppingEngine) AddToCart(userId string, productId string, quantity int
 	// Add or update the product quantity
 	s.Users[userId].Cart[productId] += quantity
 
-	Logger.Sugar().Infof("Product %s added to cart successfully by user: %s", productId, userId)
+	s.logger(ctx).Sugar().Infof("Product %s added to cart successfully by user: %s", productId, userId)
 	return s.Users[userId].Cart, nil
 }
 
-func (s *shoppingEngine) GetCart(userId string) (map[string]int, error) {
+func (s *shoppingEngine) GetCart(ctx context.Context, userId string) (map[string]int, error) {
 	// Check if user exists
-	_, err := s.GetUser(userId)
+	_, err := s.GetUser(ctx, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -35,69 +37,101 @@ func (s *shoppingEngine) GetCart(userId string) (map[string]int, error) {
 }
 
 // GetDiscountCoupon retrieves a discount coupon for the user, if applicable
-func (s *shoppingEngine) GetDiscountCoupon(userId string) (string, error) {
+func (s *shoppingEngine) GetDiscountCoupon(ctx context.Context, userId string) (string, error) {
 	// Check if user exists
-	_, err := s.GetUser(userId)
+	_, err := s.GetUser(ctx, userId)
 	if err != nil {
 		return "", err
 	}
 
-	// Check if the order counter matches the discount interval
-	if s.OrderBook.Counter%s.DiscountInterval != 0 {
-		return "", fmt.Errorf("Discount code not applicable")
+	// Check if the user is eligible under the coupon policy
+	s.OrderBook.OrderMutex.Lock()
+	eligible := s.isCouponEligible(ctx, userId)
+	s.OrderBook.OrderMutex.Unlock()
+	if !eligible {
+		return "", newError(ErrCouponInvalid, "Discount code not applicable")
 	}
 
-	return s.GenerateDiscountCouponForUser(userId), nil
+	return s.GenerateDiscountCouponForUser(ctx, userId), nil
+}
+
+// CheckoutOptions holds the optional parameters of a checkout
+type CheckoutOptions struct {
+	CouponCode     string    `json:"coupon_code"`     // Discount coupon to apply
+	WalletAmount   float64   `json:"wallet_amount"`   // Amount to pay from the user's wallet
+	RedeemPoints   int       `json:"redeem_points"`   // Loyalty points to redeem as a discount
 }
 
 // Checkout processes the user's cart and applies a coupon if valid
-func (s *shoppingEngine) Checkout(userId string, couponCode string) (*order, error) {
+func (s *shoppingEngine) Checkout(ctx context.Context, userId string, couponCode string) (*order, error) {
+	return s.CheckoutWithOptions(ctx, userId, CheckoutOptions{CouponCode: couponCode})
+}
+
+// CheckoutWithOptions processes the user's cart, applying a coupon and wallet funds if requested
+func (s *shoppingEngine) CheckoutWithOptions(ctx context.Context, userId string, options CheckoutOptions) (_ *order, err error) {
+	ctx, span := startSpan(ctx, "checkout", attribute.String("user.id", userId), attribute.Bool("checkout.coupon", options.CouponCode != ""))
+	defer func() { endSpan(span, err) }()
+
 	// Check if user exists
-	_, err := s.GetUser(userId)
+	_, err = s.GetUser(ctx, userId)
 	if err != nil {
+		s.recordCheckoutFailure(utilities.FailureOther)
 		return nil, err
 	}
 
 	// Ensure cart is not empty
 	if len(s.Users[userId].Cart) == 0 {
-		return nil, fmt.Errorf("Cart is empty")
+		s.recordCheckoutFailure(utilities.FailureEmptyCart)
+		return nil, newError(ErrValidation, "Cart is empty")
+	}
+
+	// Wallet funds and points can't be negative
+	if options.WalletAmount < 0 || options.RedeemPoints < 0 {
+		s.recordCheckoutFailure(utilities.FailureOther)
+		return nil, newError(ErrValidation, "Wallet amount and points can't be negative")
 	}
 
 	// Calculate total amount of items in the cart
+	_, priceSpan := startSpan(ctx, "cart.price")
 	var amount float64
 	for productId, quantity := range s.Users[userId].Cart {
 		amount += float64(s.Inventory.Products[productId].GetPrice()) * float64(quantity)
 	}
+	priceSpan.SetAttributes(attribute.Float64("cart.amount", amount))
+	priceSpan.End()
 	var currentOrder *order
 	// Check if coupon code is provided
-	if couponCode != "" {
+	if options.CouponCode != "" {
 		// Validate the coupon code
-		if s.Coupons[userId] == "" || s.Coupons[userId] != couponCode {
-			return nil, fmt.Errorf("Invalid coupon code")
+		if s.Coupons[userId] == "" || s.Coupons[userId] != options.CouponCode {
+			s.recordCheckoutFailure(utilities.FailureInvalidCoupon)
+			return nil, newError(ErrCouponInvalid, "Invalid coupon code")
 		}
 
 		// Place the order with discount
-		currentOrder, err = s.PlaceOrder(userId, amount, couponCode)
+		currentOrder, err = s.PlaceOrder(ctx, userId, amount, options)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		// Place the order without any discount
-		currentOrder, err = s.PlaceOrder(userId, amount, couponCode)
+		currentOrder, err = s.PlaceOrder(ctx, userId, amount, options)
 		if err != nil {
 			return nil, err
 		}
 	}
-	s.Coupons = make(map[string]string)
-	Logger.Sugar().Info("Checkout successful!")
+	// Coupons are single use
+	delete(s.Coupons, userId)
+	s.logger(ctx).Sugar().Info("Checkout successful!")
 	return currentOrder, nil
 }
 
-func (s *shoppingEngine) GenerateDiscountCouponForUser(userId string) string {
+func (s *shoppingEngine) GenerateDiscountCouponForUser(ctx context.Context, userId string) string {
 	// Generate a new coupon code if it doesn't already exist
 	if s.Coupons[userId] == "" {
 		s.Coupons[userId] = generateCouponCode(5)
-		Logger.Sugar().Info("Discount coupon generated successfully!")
+		utilities.Metrics.CouponsIssued.WithLabelValues(s.Tenant).Inc()
+		s.logger(ctx).Sugar().Info("Discount coupon generated successfully!")
 	}
 	return s.Coupons[userId]
 }
\ No newline at end of file
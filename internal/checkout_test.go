@@ -1,22 +1,26 @@
 package internal
 
 import (
+	"context"
 	"testing"
+	"time"
+	"github.com/ecommerce-store/config"
 	"github.com/stretchr/testify/assert"
 )
 
 // Helper function to create a mock shopping engine and users
-func createMockEngine() *shoppingEngine {
-	engine := &shoppingEngine{
-		Users:     make(map[string]*user),
-		UserMap:   make(map[string]string),
-		Coupons:   make(map[string]string),
-		Inventory: newInventory(),
-		OrderBook: newOrderBook(),
-		DiscountInterval: 2, // Every 2nd order is applicable for discount
+func createMockEngine(options ...EngineOption) *shoppingEngine {
+	cfg := config.Default()
+	cfg.Auth.WebhookSecret = "webhook-secret"
+	cfg.Checkout.IdempotencyWindow.Duration = time.Hour
+	cfg.Promotions.Loyalty.Expiry.Duration = 24 * time.Hour
+	defaults := []EngineOption{
+		WithCouponPolicy(&everyNthOrderPolicy{Interval: 2}), // Every 2nd order is applicable for discount
+		WithPaymentProvider(NewFakeGateway()),
 	}
+	options = append(defaults, options...)
 
-	return engine
+	return newShoppingEngine(cfg, options...)
 }
 
 // Test AddToCart for a valid product
@@ -25,27 +29,27 @@ func TestAddToCart_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("David", "david@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "David", "david@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Adity", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Adity", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	cart, err := shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	cart, err := shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
@@ -57,20 +61,20 @@ func TestAddToCart_UserNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("Shalom", "shalom@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Shalom", "shalom@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
-	cart, err := shoppingApp.AddToCart("NonExistentUser", p1.Id, 5)
+	cart, err := shoppingApp.AddToCart(context.Background(), "NonExistentUser", p1.Id, 5)
 
 	// Assert
 	assert.Error(t, err)
@@ -82,13 +86,13 @@ func TestAddToCart_ProductNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	cart, err := shoppingApp.AddToCart(user.Id, "NonExistentProduct", 5)
+	cart, err := shoppingApp.AddToCart(context.Background(), user.Id, "NonExistentProduct", 5)
 
 	// Assert
 	assert.Error(t, err)
@@ -100,47 +104,47 @@ func TestGetDiscountCoupon_ValidCoupon(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("Ram", "ram@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Ram", "ram@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 2", "Description of product 2", 20, seller.Id, 199.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 20, seller.Id, 199.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p2)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	_, err = shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, order)
 
 	// Act
-	coupon, err := shoppingApp.GetDiscountCoupon(user.Id)
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
@@ -152,28 +156,28 @@ func TestGetDiscountCoupon_InvalidCoupon(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("kiran", "kiran@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "kiran", "kiran@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
 	// Act
-	coupon, err := shoppingApp.GetDiscountCoupon(user.Id)
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
 
 	// Assert
 	assert.Error(t, err)
@@ -186,45 +190,45 @@ func TestCheckout_Success_NoCoupon(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("salman", "salman@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "salman", "salman@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 2", "Description of product 2", 20, seller.Id, 199.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 20, seller.Id, 199.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p2)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	_, err = shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
-	_, err = shoppingApp.AddToCart(user.Id, p2.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p2.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.NoError(t, err)
@@ -234,62 +238,62 @@ func TestCheckout_Success_NoCoupon(t *testing.T) {
 // Test Checkout for a valid order with a coupon
 func TestCheckout_Success_WithCoupon(t *testing.T) {
 	shoppingApp := createMockEngine()
-	shoppingApp.DiscountInterval = 2
+	shoppingApp.SetCouponPolicy(context.Background(), &everyNthOrderPolicy{Interval: 2})
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("Devi prasad", "devi@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Devi prasad", "devi@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 2", "Description of product 2", 20, seller.Id, 199.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 20, seller.Id, 199.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p2)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Adity", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Adity", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	_, err = shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, order)
 
-	_, err = shoppingApp.AddToCart(user.Id, p2.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p2.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	coupon, err := shoppingApp.GetDiscountCoupon(user.Id)
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotEmpty(t, coupon)
 
 	// Act
-	order, err = shoppingApp.Checkout(user.Id, coupon)
+	order, err = shoppingApp.Checkout(context.Background(), user.Id, coupon)
 
 	// Assert
 	assert.NoError(t, err)
@@ -299,43 +303,43 @@ func TestCheckout_Success_WithCoupon(t *testing.T) {
 // Test Checkout for a user with an invalid coupon
 func TestCheckout_InvalidCoupon(t *testing.T) {
 	shoppingApp := createMockEngine()
-	shoppingApp.DiscountInterval = 2
+	shoppingApp.SetCouponPolicy(context.Background(), &everyNthOrderPolicy{Interval: 2})
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("Piyush", "piyush@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Piyush", "piyush@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 2", "Description of product 2", 20, seller.Id, 199.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 20, seller.Id, 199.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p2)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	_, err = shoppingApp.AddToCart(user.Id, p1.Id, 5)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 5)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "INVALID_COUPON")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "INVALID_COUPON")
 
 	// Assert
 	assert.Error(t, err)
@@ -345,31 +349,31 @@ func TestCheckout_InvalidCoupon(t *testing.T) {
 // Test Checkout for an empty cart
 func TestCheckout_EmptyCart(t *testing.T) {
 	shoppingApp := createMockEngine()
-	shoppingApp.DiscountInterval = 2
+	shoppingApp.SetCouponPolicy(context.Background(), &everyNthOrderPolicy{Interval: 2})
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("John", "john@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "John", "john@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.Error(t, err)
@@ -381,36 +385,52 @@ func TestCheckout_InsufficientStock(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller and get the user ID
-	seller, err := shoppingApp.RegisterUser("John Doe", "john.doe@example.com")
+	seller, err := shoppingApp.RegisterUser(context.Background(), "John Doe", "john.doe@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, seller)
 	
 	// Act
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Aditya", "aditya@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
-	cart, err := shoppingApp.AddToCart(user.Id, p1.Id, 12)
+	cart, err := shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 12)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	order, err := shoppingApp.Checkout(user.Id, "")
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
 
 	// Assert
 	assert.Error(t, err)
 	assert.Nil(t, order)
 	assert.Equal(t, user.Cart, cart) // Cart should remain unchanged
-}
\ No newline at end of file
+}
+// Test cancelled orders no longer count towards the platform analytics
+func TestCancelOrder_RemovedFromAnalytics(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	// Act
+	_, err := shoppingApp.CancelOrder(context.Background(), order.UserId, order.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	items, amount, discount, coupons := shoppingApp.OrderBook.GetAnalytics()
+	assert.Equal(t, 0, items)
+	assert.Equal(t, 0.0, amount)
+	assert.Equal(t, 0.0, discount)
+	assert.Equal(t, 0, coupons)
+}
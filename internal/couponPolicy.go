@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// Coupon policy types accepted by NewCouponPolicy
+const (
+	CouponPolicyEveryNthOrder = "every_nth_order" // Every Nth order of the customer
+	CouponPolicyFirstOrder    = "first_order"     // The customer's first order
+	CouponPolicyMinSpend      = "min_spend"       // Customers who spent at least MinSpend in the last WindowDays
+)
+
+// CouponPolicy decides whether a customer may get a discount coupon for their next order
+type CouponPolicy interface {
+	// Eligible reports whether a customer with the given order history qualifies for a coupon
+	Eligible(history []*order, now time.Time) bool
+	// Spec returns the configuration the policy was built from
+	Spec() CouponPolicySpec
+}
+
+// CouponPolicySpec is the serializable configuration of a coupon policy
+type CouponPolicySpec struct {
+	Type         string    `json:"type"`                    // One of the CouponPolicy* types
+	Interval     int       `json:"interval,omitempty"`      // N for every_nth_order
+	MinSpend     float64   `json:"min_spend,omitempty"`     // Spend threshold for min_spend
+	WindowDays   int       `json:"window_days,omitempty"`   // Lookback window for min_spend
+}
+
+// NewCouponPolicy builds a coupon policy from its configuration
+func NewCouponPolicy(spec CouponPolicySpec) (CouponPolicy, error) {
+	switch spec.Type {
+	case CouponPolicyEveryNthOrder:
+		if spec.Interval <= 0 {
+			return nil, newError(ErrValidation, "Interval must be positive")
+		}
+		return &everyNthOrderPolicy{Interval: spec.Interval}, nil
+	case CouponPolicyFirstOrder:
+		return &firstOrderPolicy{}, nil
+	case CouponPolicyMinSpend:
+		if spec.MinSpend <= 0 || spec.WindowDays <= 0 {
+			return nil, newError(ErrValidation, "Minimum spend and window days must be positive")
+		}
+		return &minSpendPolicy{MinSpend: spec.MinSpend, WindowDays: spec.WindowDays}, nil
+	}
+	return nil, newError(ErrValidation, "Unknown coupon policy type %q", spec.Type)
+}
+
+// everyNthOrderPolicy rewards every Nth order of a customer
+type everyNthOrderPolicy struct {
+	Interval int // Every Interval-th order is eligible
+}
+
+// Eligible returns true if the customer's next order is a multiple of the interval
+func (p *everyNthOrderPolicy) Eligible(history []*order, now time.Time) bool {
+	return (countActive(history)+1)%p.Interval == 0
+}
+
+// Spec returns the configuration of the policy
+func (p *everyNthOrderPolicy) Spec() CouponPolicySpec {
+	return CouponPolicySpec{Type: CouponPolicyEveryNthOrder, Interval: p.Interval}
+}
+
+// firstOrderPolicy rewards a customer's first order
+type firstOrderPolicy struct{}
+
+// Eligible returns true if the customer has no orders yet
+func (p *firstOrderPolicy) Eligible(history []*order, now time.Time) bool {
+	return countActive(history) == 0
+}
+
+// Spec returns the configuration of the policy
+func (p *firstOrderPolicy) Spec() CouponPolicySpec {
+	return CouponPolicySpec{Type: CouponPolicyFirstOrder}
+}
+
+// minSpendPolicy rewards customers who spent enough recently
+type minSpendPolicy struct {
+	MinSpend     float64 // Amount the customer must have spent
+	WindowDays   int     // Number of days to look back
+}
+
+// Eligible returns true if the customer spent at least MinSpend within the window
+func (p *minSpendPolicy) Eligible(history []*order, now time.Time) bool {
+	since := now.AddDate(0, 0, -p.WindowDays)
+	var spent float64
+	for _, order := range history {
+		if order.isActive() && order.CreatedAt.After(since) {
+			spent += order.AmountToPay
+		}
+	}
+	return spent >= p.MinSpend
+}
+
+// Spec returns the configuration of the policy
+func (p *minSpendPolicy) Spec() CouponPolicySpec {
+	return CouponPolicySpec{Type: CouponPolicyMinSpend, MinSpend: p.MinSpend, WindowDays: p.WindowDays}
+}
+
+// countActive returns the number of orders that weren't cancelled, refunded or failed
+func countActive(history []*order) int {
+	var count int
+	for _, order := range history {
+		if order.isActive() {
+			count++
+		}
+	}
+	return count
+}
+
+// GetCouponPolicy returns the policy currently used to hand out coupons
+func (s *shoppingEngine) GetCouponPolicy(ctx context.Context) CouponPolicy {
+	s.policyMutex.RLock()
+	defer s.policyMutex.RUnlock()
+
+	return s.couponPolicy
+}
+
+// SetCouponPolicy replaces the policy used to hand out coupons
+func (s *shoppingEngine) SetCouponPolicy(ctx context.Context, policy CouponPolicy) {
+	s.policyMutex.Lock()
+	defer s.policyMutex.Unlock()
+
+	var before interface{}
+	if s.couponPolicy != nil {
+		before = s.couponPolicy.Spec()
+	}
+	s.couponPolicy = policy
+	s.audit(ctx, AuditCouponPolicyChanged, "coupon_policy", before, policy.Spec())
+	s.logger(ctx).Sugar().Infof("Coupon policy changed to %s", policy.Spec().Type)
+}
+
+// isCouponEligible checks the coupon policy against the user's orders, the caller must hold the OrderMutex
+func (s *shoppingEngine) isCouponEligible(ctx context.Context, userId string) bool {
+	return s.GetCouponPolicy(ctx).Eligible(s.OrderBook.OrdersByUserId[userId], s.clock())
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test coupon eligibility only depends on the customer's own orders
+func TestGetDiscountCoupon_PerCustomer(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	other, err := shoppingApp.RegisterUser(context.Background(), "Other", "other@example.com")
+	assert.NoError(t, err)
+
+	// Another customer places an order
+	_, err = shoppingApp.AddToCart(context.Background(), other.Id, p1.Id, 1)
+	assert.NoError(t, err)
+	_, err = shoppingApp.Checkout(context.Background(), other.Id, "")
+	assert.NoError(t, err)
+
+	// Act
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
+
+	// Assert
+	assert.Error(t, err) // User's next order is their first
+	assert.Empty(t, coupon)
+
+	// Act
+	coupon, err = shoppingApp.GetDiscountCoupon(context.Background(), other.Id)
+
+	// Assert
+	assert.NoError(t, err) // Other's next order is their second
+	assert.NotEmpty(t, coupon)
+}
+
+// Test the first order policy
+func TestCouponPolicy_FirstOrder(t *testing.T) {
+	shoppingApp := createMockEngine()
+	policy, err := NewCouponPolicy(CouponPolicySpec{Type: CouponPolicyFirstOrder})
+	assert.NoError(t, err)
+	shoppingApp.SetCouponPolicy(context.Background(), policy)
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	// Act
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
+	assert.NoError(t, err)
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, coupon)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Greater(t, order.Discount, 0.0)
+
+	// Act
+	_, err = shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// Test the minimum spend policy only counts recent orders
+func TestCouponPolicy_MinSpend(t *testing.T) {
+	policy, err := NewCouponPolicy(CouponPolicySpec{Type: CouponPolicyMinSpend, MinSpend: 100, WindowDays: 30})
+	assert.NoError(t, err)
+	now := time.Now()
+
+	history := []*order{
+		{AmountToPay: 80, Status: OrderStatusPaid, CreatedAt: now.AddDate(0, 0, -40)},
+		{AmountToPay: 60, Status: OrderStatusPaid, CreatedAt: now.AddDate(0, 0, -10)},
+		{AmountToPay: 50, Status: OrderStatusCancelled, CreatedAt: now.AddDate(0, 0, -5)},
+	}
+
+	// Assert
+	assert.False(t, policy.Eligible(history, now))
+
+	history = append(history, &order{AmountToPay: 40, Status: OrderStatusPaid, CreatedAt: now})
+	assert.True(t, policy.Eligible(history, now))
+}
+
+// Test invalid policy configurations are rejected
+func TestNewCouponPolicy_Invalid(t *testing.T) {
+	for _, spec := range []CouponPolicySpec{
+		{Type: CouponPolicyEveryNthOrder},
+		{Type: CouponPolicyMinSpend, MinSpend: 100},
+		{Type: "unknown"},
+	} {
+		// Act
+		policy, err := NewCouponPolicy(spec)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Nil(t, policy)
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of domain errors, match them with errors.Is
+var (
+	ErrNotFound          = errors.New("Not found")
+	ErrConflict          = errors.New("Conflict")
+	ErrValidation        = errors.New("Validation failed")
+	ErrInsufficientStock = errors.New("Insufficient stock")
+	ErrCouponInvalid     = errors.New("Invalid coupon")
+	ErrCouponExpired     = errors.New("Coupon has expired")
+)
+
+// domainError is an error of a known kind with a message for the caller
+type domainError struct {
+	kind    error  // One of the Err* kinds
+	message string // Message returned to the caller
+}
+
+// Error returns the message of the error
+func (e *domainError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the kind of the error so it can be matched with errors.Is
+func (e *domainError) Unwrap() error {
+	return e.kind
+}
+
+// newError creates an error of the given kind with a formatted message
+func newError(kind error, format string, args ...interface{}) error {
+	return &domainError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test engine errors can be matched by kind and keep their messages
+func TestDomainErrors(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	empty, err := shoppingApp.RegisterUser(context.Background(), "Empty", "empty@example.com")
+	assert.NoError(t, err)
+
+	_, err = shoppingApp.GetUser(context.Background(), "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.EqualError(t, err, "User not found!")
+
+	_, err = shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	_, err = shoppingApp.Checkout(context.Background(), empty.Id, "")
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.EqualError(t, err, "Cart is empty")
+
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "WRONG")
+	assert.True(t, errors.Is(err, ErrCouponInvalid))
+
+	p1.Quantity = 1
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.True(t, errors.Is(err, ErrInsufficientStock))
+	assert.False(t, errors.Is(err, ErrNotFound))
+
+	// Existing sentinels keep matching and also match their kind
+	assert.True(t, errors.Is(ErrPaymentNotFound, ErrNotFound))
+	assert.True(t, errors.Is(ErrIdempotencyKeyInProgress, ErrConflict))
+}
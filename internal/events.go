@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Types of the events published by the engine
+const (
+	EventOrderPlaced        = "order.placed"          // An order was placed
+	EventOrderStatusChanged = "order.status_changed"  // An order was cancelled or its payment status changed
+	EventStockChanged       = "product.stock_changed" // The stock of a product went up or down
+	EventStreamReset        = "stream.reset"          // Events after the subscriber's last event are no longer retained, its state must be reloaded
+)
+
+// Number of past events kept so subscribers can resume after reconnecting
+const eventHistorySize = 1000
+
+// Events buffered per subscriber before it is dropped for not keeping up
+const eventSubscriberBuffer = 64
+
+// Event is a change published to the users and sellers it concerns
+type Event struct {
+	Id        uint64      `json:"id"`         // Increasing ID, used to resume a subscription
+	Type      string      `json:"type"`       // One of the Event* types
+	Data      interface{} `json:"data"`       // Payload of the event
+	CreatedAt time.Time   `json:"created_at"` // Time the event was published
+	userId    string      // User the event concerns
+	sellerIds []string    // Sellers the event concerns
+}
+
+// EventFilter selects the events of a user or a seller
+type EventFilter struct {
+	UserId   string // Only events about this user's orders
+	SellerId string // Only events about this seller's products
+}
+
+// matches reports whether the event is selected by the filter
+func (f EventFilter) matches(event *Event) bool {
+	if f.UserId != "" && event.userId != f.UserId {
+		return false
+	}
+	if f.SellerId != "" {
+		for _, sellerId := range event.sellerIds {
+			if sellerId == f.SellerId {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// eventSubscription receives the events selected by its filter
+type eventSubscription struct {
+	filter EventFilter
+	events chan Event
+}
+
+// eventPublisher fans events out to subscribers and keeps a bounded history for resuming
+type eventPublisher struct {
+	mutex       sync.Mutex                      // Mutex to order events and guard the subscribers
+	lastId      uint64                          // ID of the last published event
+	history     []*Event                        // Most recent events, oldest first
+	subscribers map[*eventSubscription]struct{} // Active subscriptions
+	closed      bool                            // Set once the engine is closed, new subscriptions end right away
+	clock       Clock                           // Clock the events are timestamped with
+}
+
+func newEventPublisher(clock Clock) *eventPublisher {
+	return &eventPublisher{subscribers: make(map[*eventSubscription]struct{}), clock: clock}
+}
+
+// publish assigns the next ID to the event and sends it to the matching subscribers.
+// Subscribers that aren't keeping up are dropped and can resume from the last event they received.
+func (p *eventPublisher) publish(eventType string, userId string, sellerIds []string, data interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lastId++
+	event := &Event{
+		Id:        p.lastId,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: p.clock().UTC(),
+		userId:    userId,
+		sellerIds: sellerIds,
+	}
+	p.history = append(p.history, event)
+	if len(p.history) > eventHistorySize {
+		p.history = p.history[len(p.history)-eventHistorySize:]
+	}
+
+	for subscription := range p.subscribers {
+		if !subscription.filter.matches(event) {
+			continue
+		}
+		select {
+		case subscription.events <- *event:
+		default:
+			p.unsubscribe(subscription)
+		}
+	}
+}
+
+// subscribe replays the retained events after lastEventId and then follows new ones until ctx is done
+func (p *eventPublisher) subscribe(ctx context.Context, filter EventFilter, lastEventId uint64) <-chan Event {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var missed []*Event
+	// Tell resuming subscribers when events they missed were dropped from the history or lost in a restart. An ID past
+	// the last one published means the IDs started over, e.g. the state was lost, so nothing after it can be trusted.
+	retainedAfter := p.lastId
+	if len(p.history) > 0 {
+		retainedAfter = p.history[0].Id - 1
+	}
+	if lastEventId > 0 && (lastEventId < retainedAfter || lastEventId > p.lastId) {
+		missed = append(missed, &Event{
+			Id:        retainedAfter,
+			Type:      EventStreamReset,
+			Data:      map[string]interface{}{"last_event_id": lastEventId},
+			CreatedAt: p.clock().UTC(),
+		})
+	}
+	for _, event := range p.history {
+		if event.Id > lastEventId && filter.matches(event) {
+			missed = append(missed, event)
+		}
+	}
+	subscription := &eventSubscription{
+		filter: filter,
+		events: make(chan Event, len(missed)+eventSubscriberBuffer),
+	}
+	for _, event := range missed {
+		subscription.events <- *event
+	}
+	if p.closed {
+		close(subscription.events)
+		return subscription.events
+	}
+	p.subscribers[subscription] = struct{}{}
+
+	// Stop following when the subscriber goes away
+	go func() {
+		<-ctx.Done()
+		p.mutex.Lock()
+		p.unsubscribe(subscription)
+		p.mutex.Unlock()
+	}()
+	return subscription.events
+}
+
+// close ends every subscription and refuses new ones
+func (p *eventPublisher) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	for subscription := range p.subscribers {
+		p.unsubscribe(subscription)
+	}
+}
+
+// unsubscribe closes the subscription's channel if it is still open, the caller must hold the mutex
+func (p *eventPublisher) unsubscribe(subscription *eventSubscription) {
+	if _, ok := p.subscribers[subscription]; ok {
+		delete(p.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// SubscribeEvents returns a channel receiving the events selected by the filter that were published after lastEventId,
+// with 0 replaying every retained event. When events after lastEventId are no longer retained, an EventStreamReset event
+// comes first so the subscriber reloads its state instead of silently missing them. The channel is closed when ctx is done or the subscriber falls behind.
+func (s *shoppingEngine) SubscribeEvents(ctx context.Context, filter EventFilter, lastEventId uint64) <-chan Event {
+	return s.Events.subscribe(ctx, filter, lastEventId)
+}
+
+// orderSellers returns the sellers of the items in the order, each once
+func orderSellers(order *order) []string {
+	var sellerIds []string
+	seen := make(map[string]bool)
+	for _, item := range order.Items {
+		if !seen[item.SellerId] {
+			seen[item.SellerId] = true
+			sellerIds = append(sellerIds, item.SellerId)
+		}
+	}
+	return sellerIds
+}
+
+// publishOrderPlaced publishes a newly placed order
+func (s *shoppingEngine) publishOrderPlaced(order *order) {
+	s.Events.publish(EventOrderPlaced, order.UserId, orderSellers(order), map[string]interface{}{
+		"order_id":      order.Id,
+		"status":        order.Status,
+		"items":         order.Items,
+		"amount_to_pay": order.AmountToPay,
+	})
+}
+
+// publishOrderStatus publishes the current status of the order
+func (s *shoppingEngine) publishOrderStatus(order *order) {
+	s.Events.publish(EventOrderStatusChanged, order.UserId, orderSellers(order), order.statusUpdate())
+}
+
+// publishStock publishes the current stock of the products of the items
+func (s *shoppingEngine) publishStock(items []*lineItem) {
+	for _, item := range items {
+		product := s.Inventory.Products[item.ProductId]
+		if product == nil {
+			continue
+		}
+		s.Events.publish(EventStockChanged, "", []string{product.SellerId}, map[string]interface{}{
+			"product_id": product.Id,
+			"quantity":   product.Quantity,
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+// Helper function to read the events already delivered to a subscriber
+func drainEvents(events <-chan Event) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+// Helper function to list the types of events
+func eventTypes(events []Event) []string {
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// Test users receive the events of their orders and sellers those of their products
+func TestSubscribeEvents_Filters(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	userEvents := shoppingApp.SubscribeEvents(ctx, EventFilter{UserId: user.Id}, 0)
+	sellerEvents := shoppingApp.SubscribeEvents(ctx, EventFilter{SellerId: p1.SellerId}, 0)
+	otherEvents := shoppingApp.SubscribeEvents(ctx, EventFilter{SellerId: "other-seller"}, 0)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, order.Id)
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []string{EventOrderPlaced, EventOrderStatusChanged}, eventTypes(drainEvents(userEvents)))
+	seller := drainEvents(sellerEvents)
+	assert.Equal(t, []string{EventOrderPlaced, EventStockChanged, EventStockChanged, EventOrderStatusChanged}, eventTypes(seller))
+	assert.Equal(t, OrderStatusPaid, seller[0].Data.(map[string]interface{})["status"])
+	assert.Equal(t, map[string]interface{}{"product_id": p1.Id, "quantity": 8}, seller[1].Data)
+	assert.Equal(t, map[string]interface{}{"product_id": p1.Id, "quantity": 10}, seller[2].Data)
+	assert.Equal(t, OrderStatusCancelled, seller[3].Data.(OrderStatusUpdate).Status)
+	assert.Empty(t, drainEvents(otherEvents))
+}
+
+// Test subscribers resume after the last event they received
+func TestSubscribeEvents_Resume(t *testing.T) {
+	shoppingApp := createMockEngine()
+	for i := 0; i < 3; i++ {
+		shoppingApp.Events.publish(EventStockChanged, "", []string{"seller"}, i)
+	}
+
+	// Act
+	events := drainEvents(shoppingApp.SubscribeEvents(context.Background(), EventFilter{SellerId: "seller"}, 1))
+
+	// Assert
+	assert.Len(t, events, 2)
+	assert.Equal(t, uint64(2), events[0].Id)
+	assert.Equal(t, 1, events[0].Data)
+	assert.Equal(t, uint64(3), events[1].Id)
+}
+
+// Test subscribers resuming from before a restart are told the events they missed are lost
+func TestSubscribeEvents_ResetAfterRestart(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	shoppingApp := createMockEngine(WithStateStore(store))
+	for i := 0; i < 3; i++ {
+		shoppingApp.Events.publish(EventStockChanged, "", []string{"seller"}, i)
+	}
+	assert.NoError(t, shoppingApp.SaveState(context.Background()))
+	restored := createMockEngine(WithStateStore(store))
+	assert.NoError(t, restored.LoadState(context.Background()))
+	restored.Events.publish(EventStockChanged, "", []string{"seller"}, 3)
+
+	// Act
+	resumed := drainEvents(restored.SubscribeEvents(context.Background(), EventFilter{SellerId: "seller"}, 1))
+	upToDate := drainEvents(restored.SubscribeEvents(context.Background(), EventFilter{SellerId: "seller"}, 3))
+
+	// Assert
+	assert.Equal(t, []string{EventStreamReset, EventStockChanged}, eventTypes(resumed))
+	assert.Equal(t, uint64(3), resumed[0].Id)
+	assert.Equal(t, uint64(4), resumed[1].Id)
+	assert.Equal(t, []string{EventStockChanged}, eventTypes(upToDate))
+}
+
+// Test subscribers resuming past the last published event are told to reset when the state was lost
+func TestSubscribeEvents_ResetWhenAhead(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	// Act
+	resumed := drainEvents(shoppingApp.SubscribeEvents(context.Background(), EventFilter{SellerId: "seller"}, 7))
+	shoppingApp.Events.publish(EventStockChanged, "", []string{"seller"}, 0)
+	upToDate := drainEvents(shoppingApp.SubscribeEvents(context.Background(), EventFilter{SellerId: "seller"}, 1))
+
+	// Assert
+	assert.Equal(t, []string{EventStreamReset}, eventTypes(resumed))
+	assert.Equal(t, uint64(0), resumed[0].Id)
+	assert.Equal(t, uint64(7), resumed[0].Data.(map[string]interface{})["last_event_id"])
+	assert.Empty(t, upToDate)
+}
+
+// Test subscribers that fall behind are dropped once their buffer is full
+func TestSubscribeEvents_SlowSubscriber(t *testing.T) {
+	shoppingApp := createMockEngine()
+	events := shoppingApp.SubscribeEvents(context.Background(), EventFilter{}, 0)
+
+	// Act
+	for i := 0; i <= eventSubscriberBuffer; i++ {
+		shoppingApp.Events.publish(EventStockChanged, "", []string{"seller"}, i)
+	}
+
+	// Assert
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, eventSubscriberBuffer, received)
+	assert.Empty(t, shoppingApp.Events.subscribers)
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PaymentOperation names an operation of the PaymentProvider interface
+type PaymentOperation string
+
+const (
+	PaymentAuthorize PaymentOperation = "authorize"
+	PaymentCapture   PaymentOperation = "capture"
+	PaymentVoid      PaymentOperation = "void"
+	PaymentRefund    PaymentOperation = "refund"
+)
+
+// fakePayment is the state of a payment held by the FakeGateway
+type fakePayment struct {
+	Id          string    `json:"id"`             // Payment ID returned by Authorize
+	UserId      string    `json:"user_id"`        // User who was charged
+	Authorized  float64   `json:"authorized"`     // Amount authorized
+	Captured    float64   `json:"captured"`       // Amount captured
+	Refunded    float64   `json:"refunded"`       // Amount refunded
+	Voided      bool      `json:"voided"`         // Whether the authorization was voided
+}
+
+// FakeGateway is a deterministic in-process PaymentProvider with scriptable declines
+type FakeGateway struct {
+	payments   map[string]*fakePayment              // Payments indexed by payment ID
+	declines   map[PaymentOperation][]string        // Queued decline reasons per operation
+	counter    int                                  // Counter for payment numbering
+	mutex      sync.Mutex                           // Mutex to keep the gateway safe for concurrent use
+}
+
+// NewFakeGateway creates and returns a new FakeGateway object
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		payments: make(map[string]*fakePayment),
+		declines: make(map[PaymentOperation][]string),
+	}
+}
+
+// DeclineNext makes the next call of the operation fail with the given reason
+func (g *FakeGateway) DeclineNext(operation PaymentOperation, reason string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.declines[operation] = append(g.declines[operation], reason)
+}
+
+// Payment returns a copy of the payment with the given ID
+func (g *FakeGateway) Payment(paymentId string) (fakePayment, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	payment, ok := g.payments[paymentId]
+	if !ok {
+		return fakePayment{}, false
+	}
+	return *payment, true
+}
+
+// Authorize reserves the amount and returns a sequential payment ID
+func (g *FakeGateway) Authorize(userId string, amount float64) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.scriptedDecline(PaymentAuthorize); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("%w: invalid amount", ErrPaymentDeclined)
+	}
+
+	g.counter++
+	id := fmt.Sprintf("pay_%d", g.counter)
+	g.payments[id] = &fakePayment{Id: id, UserId: userId, Authorized: amount}
+	return id, nil
+}
+
+// Capture collects up to the authorized amount
+func (g *FakeGateway) Capture(paymentId string, amount float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.scriptedDecline(PaymentCapture); err != nil {
+		return err
+	}
+	payment, ok := g.payments[paymentId]
+	if !ok {
+		return newError(ErrNotFound, "Payment %s not found", paymentId)
+	}
+	if payment.Voided {
+		return newError(ErrConflict, "Payment %s has been voided", paymentId)
+	}
+	if payment.Captured+amount > payment.Authorized {
+		return fmt.Errorf("%w: capture exceeds authorized amount", ErrPaymentDeclined)
+	}
+	payment.Captured += amount
+	return nil
+}
+
+// Void cancels an authorization that has not been captured
+func (g *FakeGateway) Void(paymentId string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.scriptedDecline(PaymentVoid); err != nil {
+		return err
+	}
+	payment, ok := g.payments[paymentId]
+	if !ok {
+		return newError(ErrNotFound, "Payment %s not found", paymentId)
+	}
+	if payment.Captured > 0 {
+		return newError(ErrConflict, "Payment %s has already been captured", paymentId)
+	}
+	payment.Voided = true
+	return nil
+}
+
+// Refund returns up to the captured amount
+func (g *FakeGateway) Refund(paymentId string, amount float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.scriptedDecline(PaymentRefund); err != nil {
+		return err
+	}
+	payment, ok := g.payments[paymentId]
+	if !ok {
+		return newError(ErrNotFound, "Payment %s not found", paymentId)
+	}
+	if payment.Refunded+amount > payment.Captured {
+		return newError(ErrValidation, "Refund exceeds captured amount for payment %s", paymentId)
+	}
+	payment.Refunded += amount
+	return nil
+}
+
+func (g *FakeGateway) paymentState() *PaymentState {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	state := &PaymentState{Counter: g.counter}
+	for _, payment := range g.payments {
+		state.Payments = append(state.Payments, *payment)
+	}
+	return state
+}
+
+func (g *FakeGateway) restorePayments(state *PaymentState) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.counter = state.Counter
+	g.payments = make(map[string]*fakePayment, len(state.Payments))
+	for _, payment := range state.Payments {
+		payment := payment
+		g.payments[payment.Id] = &payment
+	}
+}
+
+// scriptedDecline pops the next queued decline for the operation, if any
+func (g *FakeGateway) scriptedDecline(operation PaymentOperation) error {
+	reasons := g.declines[operation]
+	if len(reasons) == 0 {
+		return nil
+	}
+	g.declines[operation] = reasons[1:]
+	return fmt.Errorf("%w: %s", ErrPaymentDeclined, reasons[0])
+}
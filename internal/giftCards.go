@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Length of gift card codes, 36^16 codes are too many to guess
+const giftCardCodeLength = 16
+
+// giftCard is a prepaid code that can be redeemed into a wallet
+type giftCard struct {
+	Code          string       `json:"code"`                   // Code to redeem the gift card
+	Amount        float64      `json:"amount"`                 // Value credited on redemption
+	PurchasedBy   string       `json:"purchased_by"`           // User who bought the gift card
+	PaymentId     string       `json:"payment_id"`             // Payment used to buy the gift card
+	RedeemedBy    string       `json:"redeemed_by,omitempty"`  // User who redeemed the gift card
+	CreatedAt     time.Time    `json:"created_at"`             // Time of purchase
+	RedeemedAt    *time.Time   `json:"redeemed_at,omitempty"`  // Time of redemption
+}
+
+// giftCardBook keeps track of all gift cards sold
+type giftCardBook struct {
+	Cards   map[string]*giftCard   // Map of gift cards by code
+	Mutex   *sync.Mutex            // Mutex to prevent a card from being redeemed twice
+}
+
+// newGiftCardBook creates and returns a new giftCardBook object
+func newGiftCardBook() *giftCardBook {
+	return &giftCardBook{
+		Cards: make(map[string]*giftCard),
+		Mutex: &sync.Mutex{},
+	}
+}
+
+// PurchaseGiftCard charges the user and issues a new gift card of the given amount
+func (s *shoppingEngine) PurchaseGiftCard(ctx context.Context, userId string, amount float64) (*giftCard, error) {
+	// Check if user exists
+	_, err := s.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, newError(ErrValidation, "Gift card amount must be positive")
+	}
+
+	// Generate the code before charging, so a failure can't leave the user charged without a card
+	code, err := generateGiftCardCode(giftCardCodeLength)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate gift card code: %w", err)
+	}
+
+	// Charge the user before issuing the card
+	paymentId, err := s.chargeOrder(ctx, userId, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	s.GiftCards.Mutex.Lock()
+	defer s.GiftCards.Mutex.Unlock()
+
+	// Draw again in the unlikely case the code has been issued before
+	for s.GiftCards.Cards[code] != nil {
+		if code, err = generateGiftCardCode(giftCardCodeLength); err != nil {
+			return nil, fmt.Errorf("unable to generate gift card code: %w", err)
+		}
+	}
+
+	card := &giftCard{
+		Code:        code,
+		Amount:      amount,
+		PurchasedBy: userId,
+		PaymentId:   paymentId,
+		CreatedAt:   s.clock().UTC(),
+	}
+	s.GiftCards.Cards[code] = card
+
+	s.logger(ctx).Sugar().Infof("Gift card purchased successfully by user: %s", userId)
+	return card, nil
+}
+
+// RedeemGiftCard credits the gift card value to the user's wallet
+func (s *shoppingEngine) RedeemGiftCard(ctx context.Context, userId string, code string) (*walletView, error) {
+	user, err := s.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.GiftCards.Mutex.Lock()
+	defer s.GiftCards.Mutex.Unlock()
+
+	card := s.GiftCards.Cards[code]
+	if card == nil {
+		return nil, newError(ErrNotFound, "Gift card not found")
+	}
+	if card.RedeemedAt != nil {
+		return nil, newError(ErrConflict, "Gift card has already been redeemed")
+	}
+
+	if _, err := user.Wallet.Credit(card.Amount, WalletReasonGiftCard, card.Code); err != nil {
+		return nil, err
+	}
+	now := s.clock().UTC()
+	card.RedeemedBy = userId
+	card.RedeemedAt = &now
+
+	s.logger(ctx).Sugar().Infof("Gift card redeemed successfully by user: %s", userId)
+	return user.Wallet.view(), nil
+}
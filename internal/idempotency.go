@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is replayed with a different request
+	ErrIdempotencyKeyReused = newError(ErrConflict, "Idempotency key has already been used for a different request")
+	// ErrIdempotencyKeyInProgress is returned when a request with the same key hasn't finished yet
+	ErrIdempotencyKeyInProgress = newError(ErrConflict, "A request with this idempotency key is already in progress")
+)
+
+// idempotencyKey scopes a client supplied key to the user, so users can't replay or block each other's checkouts
+type idempotencyKey struct {
+	UserId string // User the key was used by
+	Key    string // Client supplied idempotency key
+}
+
+// idempotencyRecord remembers the outcome of a request made with an idempotency key
+type idempotencyRecord struct {
+	Key          string       // Client supplied idempotency key
+	Fingerprint  string       // Hash of the request the key was first used with
+	Cart         string       // Hash of the cart that was checked out
+	Order        *order       // Copy of the order as first returned, nil while in progress
+	CreatedAt    time.Time    // Time at which the key was first seen
+}
+
+// idempotencyStore keeps idempotency records until they expire
+type idempotencyStore struct {
+	Records   map[idempotencyKey]*idempotencyRecord   // Records indexed by user and idempotency key
+	Window    time.Duration                   // How long a key is remembered
+	Mutex     *sync.Mutex                     // Mutex to prevent concurrent use of the same key
+	clock     Clock                           // Clock the records are timestamped and expired with
+}
+
+// newIdempotencyStore creates and returns a new idempotencyStore object
+func newIdempotencyStore(window time.Duration, clock Clock) *idempotencyStore {
+	return &idempotencyStore{
+		Records: make(map[idempotencyKey]*idempotencyRecord),
+		Window:  window,
+		Mutex:   &sync.Mutex{},
+		clock:   clock,
+	}
+}
+
+// checkoutFingerprint identifies a checkout request by its parameters
+func checkoutFingerprint(userId string, options CheckoutOptions) string {
+	walletAmount := strconv.FormatFloat(options.WalletAmount, 'f', -1, 64)
+	points := strconv.Itoa(options.RedeemPoints)
+	sum := sha256.Sum256([]byte(userId + "\x00" + options.CouponCode + "\x00" + walletAmount + "\x00" + points))
+	return hex.EncodeToString(sum[:])
+}
+
+// cartFingerprint identifies the contents of a cart, an empty cart has no fingerprint
+func cartFingerprint(cart map[string]int) string {
+	if len(cart) == 0 {
+		return ""
+	}
+	productIds := make([]string, 0, len(cart))
+	for productId := range cart {
+		productIds = append(productIds, productId)
+	}
+	sort.Strings(productIds)
+
+	hash := sha256.New()
+	for _, productId := range productIds {
+		hash.Write([]byte(productId + "\x00" + strconv.Itoa(cart[productId]) + "\x00"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// begin reserves the key for a request, or returns the previous record if the key was already used
+func (i *idempotencyStore) begin(key idempotencyKey, fingerprint string, cart string) (*idempotencyRecord, error) {
+	i.Mutex.Lock()
+	defer i.Mutex.Unlock()
+
+	i.purgeExpired()
+
+	if record, ok := i.Records[key]; ok {
+		// Same key must always be used with the same request
+		if record.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if record.Order == nil {
+			return nil, ErrIdempotencyKeyInProgress
+		}
+		// Checkout empties the cart, so a retry comes with an empty cart or the one that was ordered
+		if cart != "" && cart != record.Cart {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return record, nil
+	}
+
+	i.Records[key] = &idempotencyRecord{
+		Key:         key.Key,
+		Fingerprint: fingerprint,
+		Cart:        cart,
+		CreatedAt:   i.clock(),
+	}
+	return nil, nil
+}
+
+// complete stores a copy of the order created for the key, or releases the key if the request failed
+func (i *idempotencyStore) complete(key idempotencyKey, order *order) {
+	i.Mutex.Lock()
+	defer i.Mutex.Unlock()
+
+	if order == nil {
+		// Failed requests may be retried with the same key
+		delete(i.Records, key)
+		return
+	}
+	if record, ok := i.Records[key]; ok {
+		record.Order = order.clone()
+	}
+}
+
+// purgeExpired removes records older than the configured window
+func (i *idempotencyStore) purgeExpired() {
+	for key, record := range i.Records {
+		if i.clock().Sub(record.CreatedAt) > i.Window {
+			delete(i.Records, key)
+		}
+	}
+}
+
+// CheckoutWithIdempotencyKey checks out the user's cart at most once per user and idempotency key.
+// It returns the order as it was first returned and true when the request is a replay.
+func (s *shoppingEngine) CheckoutWithIdempotencyKey(ctx context.Context, key string, userId string, options CheckoutOptions) (*order, bool, error) {
+	var cart string
+	if user := s.Users[userId]; user != nil {
+		cart = cartFingerprint(user.Cart)
+	}
+	record, err := s.Idempotency.begin(idempotencyKey{UserId: userId, Key: key}, checkoutFingerprint(userId, options), cart)
+	if err != nil {
+		s.logger(ctx).Sugar().Debugf("Rejected checkout with idempotency key %s: %v", key, err)
+		return nil, false, err
+	}
+	if record != nil {
+		s.logger(ctx).Sugar().Infof("Replaying checkout for idempotency key %s", key)
+		return record.Order.clone(), true, nil
+	}
+
+	order, err := s.CheckoutWithOptions(ctx, userId, options)
+	s.Idempotency.complete(idempotencyKey{UserId: userId, Key: key}, order)
+	if err != nil {
+		return nil, false, err
+	}
+	return order, false, nil
+}
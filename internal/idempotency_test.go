@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to register a user with a product in the cart
+func createUserWithCart(t *testing.T, shoppingApp *shoppingEngine) (*user, *product) {
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	assert.NoError(t, err)
+
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 2)
+	assert.NoError(t, err)
+
+	return user, p1
+}
+
+// Test replaying a checkout returns the original order
+func TestCheckoutWithIdempotencyKey_Replay(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+
+	// Act
+	first, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.NotNil(t, first)
+
+	// Act
+	second, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, first, second)
+	assert.Len(t, shoppingApp.OrderBook.Orders, 1)
+	assert.Equal(t, 8, p1.Quantity) // Stock is only deducted once
+}
+
+// Test reusing a key with a different request is rejected
+func TestCheckoutWithIdempotencyKey_DifferentRequest(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	_, _, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+	assert.NoError(t, err)
+
+	// Act
+	order, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{CouponCode: "SOMECOUPON"})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	assert.False(t, replayed)
+	assert.Nil(t, order)
+}
+
+// Test reusing a key for a different cart is rejected
+func TestCheckoutWithIdempotencyKey_DifferentCart(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+
+	_, _, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 1)
+	assert.NoError(t, err)
+
+	// Act
+	order, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	assert.False(t, replayed)
+	assert.Nil(t, order)
+	assert.Equal(t, 1, user.Cart[p1.Id])
+	assert.Len(t, shoppingApp.OrderBook.Orders, 1)
+}
+
+// Test users using the same key get their own orders
+func TestCheckoutWithIdempotencyKey_PerUser(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	other, err := shoppingApp.RegisterUser(context.Background(), "Tejas", "tejas@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), other.Id, p1.Id, 1)
+	assert.NoError(t, err)
+
+	first, _, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+	assert.NoError(t, err)
+
+	// Act
+	second, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", other.Id, CheckoutOptions{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, other.Id, second.UserId)
+	assert.NotEqual(t, first.Id, second.Id)
+	assert.Equal(t, 7, p1.Quantity)
+}
+
+// Test a replay returns the order as it was placed, not as it is now
+func TestCheckoutWithIdempotencyKey_ReplayAfterCancel(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	first, _, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+	assert.NoError(t, err)
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, first.Id)
+	assert.NoError(t, err)
+
+	// Act
+	replay, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, first.Id, replay.Id)
+	assert.Equal(t, OrderStatusPaid, replay.Status)
+	assert.Equal(t, OrderStatusCancelled, shoppingApp.OrderBook.Orders[first.Id].Status)
+}
+
+// Test a failed checkout releases the key
+func TestCheckoutWithIdempotencyKey_FailureNotRemembered(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+
+	// Act
+	_, _, err = shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Empty(t, shoppingApp.Idempotency.Records)
+}
+
+// Test expired keys are forgotten
+func TestCheckoutWithIdempotencyKey_Expired(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+
+	first, _, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+	assert.NoError(t, err)
+
+	// Move the record outside of the window and refill the cart
+	shoppingApp.Idempotency.Records[idempotencyKey{UserId: user.Id, Key: "key-1"}].CreatedAt = time.Now().Add(-2 * time.Hour)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 1)
+	assert.NoError(t, err)
+
+	// Act
+	second, replayed, err := shoppingApp.CheckoutWithIdempotencyKey(context.Background(), "key-1", user.Id, CheckoutOptions{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.NotEqual(t, first.Id, second.Id)
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// loggerKey is the context key of the request-scoped logger
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the logger
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithLogFields returns a copy of ctx whose logger adds the fields to every entry
+func WithLogFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithLogger(ctx, LoggerFrom(ctx).With(fields...))
+}
+
+// LoggerFrom returns the logger carried by ctx, or the service logger if there is none
+func LoggerFrom(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return Logger
+}
+
+// logger returns the logger carried by ctx, or the engine's logger if there is none
+func (s *shoppingEngine) logger(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return s.log
+}
@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Test engine log lines carry the fields of the request-scoped logger
+func TestLoggerFrom_RequestFields(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := WithLogger(context.Background(), zap.New(core))
+	ctx = WithLogFields(ctx, zap.String("request_id", "req-1"), zap.String("user_id", user.Id))
+
+	// Act
+	_, err := shoppingApp.Checkout(ctx, user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	entries := logs.FilterMessage("Checkout successful!").All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+	assert.Equal(t, user.Id, entries[0].ContextMap()["user_id"])
+}
+
+// Test the service logger is used when the context has none
+func TestLoggerFrom_Default(t *testing.T) {
+	assert.Same(t, Logger, LoggerFrom(context.Background()))
+}
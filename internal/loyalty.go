@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"github.com/ecommerce-store/config"
+)
+
+// pointsEntryType describes a movement in a points ledger
+type pointsEntryType string
+
+const (
+	PointsEarned   pointsEntryType = "earned"   // Points earned on an order
+	PointsRedeemed pointsEntryType = "redeemed" // Points spent as a discount
+	PointsReversed pointsEntryType = "reversed" // Earned points taken back after a cancellation or refund
+	PointsRestored pointsEntryType = "restored" // Redeemed points given back after a cancellation or refund
+)
+
+// pointsEntry is a single movement in a user's points ledger
+type pointsEntry struct {
+	Id          string            `json:"id"`                    // Unique entry ID
+	Type        pointsEntryType   `json:"type"`                  // Kind of movement
+	Points      int               `json:"points"`                // Number of points moved
+	Remaining   int               `json:"remaining,omitempty"`   // Points of an earned entry not yet spent or reversed
+	OrderId     string            `json:"order_id"`              // Order the entry relates to
+	CreatedAt   time.Time         `json:"created_at"`            // Time the entry was recorded
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`  // Expiry of earned points
+	Sources     []pointsSource    `json:"sources,omitempty"`     // Points a redemption consumed, to restore them with their expiry
+}
+
+// pointsSource is the part of a redemption taken from one earned or restored entry
+type pointsSource struct {
+	EntryId     string      `json:"entry_id"`     // Entry the points were taken from
+	Points      int         `json:"points"`       // Points taken
+	ExpiresAt   time.Time   `json:"expires_at"`   // Expiry of the points taken
+}
+
+// pointsAccount holds a user's loyalty points ledger
+type pointsAccount struct {
+	Entries   []*pointsEntry   // All movements, oldest first
+	Debt      int              // Points reversed after they were spent, settled by the next points added
+	mutex     sync.Mutex       // Mutex to prevent race conditions on the ledger
+	newId     IDGenerator      // Generator of entry IDs
+}
+
+// pointsView is a copy of a points account at a given time
+type pointsView struct {
+	Balance   int             `json:"balance"`   // Points that can be redeemed, negative while a debt is outstanding
+	History   []pointsEntry   `json:"history"`   // All movements, oldest first
+}
+
+// loyaltyProgram holds the rules for earning and redeeming points
+type loyaltyProgram struct {
+	Rate          float64              // Points earned per unit of currency spent
+	PointValue    float64              // Currency value of one point at redemption
+	Expiry        time.Duration        // How long earned points stay valid
+	Multipliers   map[string]float64   // Earning multipliers by product category
+}
+
+// newPointsAccount creates and returns an empty points account
+func newPointsAccount(newId IDGenerator) *pointsAccount {
+	return &pointsAccount{newId: newId}
+}
+
+// newLoyaltyProgram creates and returns a loyaltyProgram object with its own copy of the multipliers
+func newLoyaltyProgram(settings config.Loyalty) *loyaltyProgram {
+	multipliers := make(map[string]float64, len(settings.Multipliers))
+	for category, factor := range settings.Multipliers {
+		multipliers[category] = factor
+	}
+	return &loyaltyProgram{
+		Rate:        settings.Rate,
+		PointValue:  settings.PointValue,
+		Expiry:      settings.Expiry.Duration,
+		Multipliers: multipliers,
+	}
+}
+
+// pointsFor returns the points earned by the line items of an order
+func (l *loyaltyProgram) pointsFor(items []*lineItem) int {
+	var points float64
+	for _, item := range items {
+		multiplier, ok := l.Multipliers[item.Category]
+		if !ok {
+			multiplier = 1
+		}
+		points += (item.LineTotal - item.Discount) * l.Rate * multiplier
+	}
+	return int(math.Floor(points))
+}
+
+// Balance returns the points that can still be redeemed at the given time, negative while a debt is outstanding
+func (p *pointsAccount) Balance(now time.Time) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.balance(now)
+}
+
+// History returns a copy of the ledger entries
+func (p *pointsAccount) History() []pointsEntry {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	history := make([]pointsEntry, 0, len(p.Entries))
+	for _, entry := range p.Entries {
+		history = append(history, *entry)
+	}
+	return history
+}
+
+// view returns the balance and a copy of the ledger at the given time
+func (p *pointsAccount) view(now time.Time) *pointsView {
+	return &pointsView{Balance: p.Balance(now), History: p.History()}
+}
+
+// earn records points that expire after the given duration
+func (p *pointsAccount) earn(entryType pointsEntryType, points int, orderId string, now time.Time, expiry time.Duration) {
+	if points <= 0 {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.add(entryType, points, orderId, now, now.Add(expiry))
+}
+
+// add records points that expire at the given time, settling the debt first, the caller must hold the mutex
+func (p *pointsAccount) add(entryType pointsEntryType, points int, orderId string, now time.Time, expiresAt time.Time) {
+	settled := min(p.Debt, points)
+	p.Debt -= settled
+	p.Entries = append(p.Entries, &pointsEntry{
+		Id:        p.newId(),
+		Type:      entryType,
+		Points:    points,
+		Remaining: points - settled,
+		OrderId:   orderId,
+		CreatedAt: now,
+		ExpiresAt: &expiresAt,
+	})
+}
+
+// redeem spends points, consuming the ones that expire first
+func (p *pointsAccount) redeem(points int, orderId string, now time.Time) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if points > p.balance(now) {
+		return newError(ErrValidation, "Insufficient loyalty points")
+	}
+
+	p.Entries = append(p.Entries, &pointsEntry{
+		Id:        p.newId(),
+		Type:      PointsRedeemed,
+		Points:    points,
+		OrderId:   orderId,
+		CreatedAt: now,
+		Sources:   p.consume(points, now),
+	})
+	return nil
+}
+
+// consume takes points from the entries that expire first and returns what was taken, the caller must hold the mutex
+func (p *pointsAccount) consume(points int, now time.Time) []pointsSource {
+	var sources []pointsSource
+	for _, entry := range p.spendable(now) {
+		if points == 0 {
+			break
+		}
+		used := min(entry.Remaining, points)
+		entry.Remaining -= used
+		points -= used
+		sources = append(sources, pointsSource{EntryId: entry.Id, Points: used, ExpiresAt: *entry.ExpiresAt})
+	}
+	return sources
+}
+
+// reverse takes back all points earned on an order and returns the number of points taken back.
+// Points already spent are taken from the rest of the balance, and become a debt when the balance doesn't cover them.
+func (p *pointsAccount) reverse(orderId string, now time.Time) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var reversed, spent int
+	for _, entry := range p.Entries {
+		if entry.Type == PointsEarned && entry.OrderId == orderId {
+			reversed += entry.Points
+			spent += entry.Points - entry.Remaining
+			entry.Remaining = 0
+		}
+	}
+	for _, source := range p.consume(spent, now) {
+		spent -= source.Points
+	}
+	p.Debt += spent
+	if reversed > 0 {
+		p.Entries = append(p.Entries, &pointsEntry{
+			Id:        p.newId(),
+			Type:      PointsReversed,
+			Points:    reversed,
+			OrderId:   orderId,
+			CreatedAt: now,
+		})
+	}
+	return reversed
+}
+
+// restoreRedeemed gives back the points redeemed on an order with the expiry they had, and returns the number of points given back
+func (p *pointsAccount) restoreRedeemed(orderId string, now time.Time, expiry time.Duration) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var restored int
+	for _, entry := range p.Entries {
+		if entry.Type != PointsRedeemed || entry.OrderId != orderId {
+			continue
+		}
+		if entry.Sources == nil {
+			// Redeemed before sources were recorded, the original expiry is unknown
+			p.add(PointsRestored, entry.Points, orderId, now, now.Add(expiry))
+		}
+		for _, source := range entry.Sources {
+			p.add(PointsRestored, source.Points, orderId, now, source.ExpiresAt)
+		}
+		restored += entry.Points
+	}
+	return restored
+}
+
+// balance sums unexpired points less the debt, the caller must hold the mutex
+func (p *pointsAccount) balance(now time.Time) int {
+	total := -p.Debt
+	for _, entry := range p.spendable(now) {
+		total += entry.Remaining
+	}
+	return total
+}
+
+// spendable returns unexpired entries with points left, the caller must hold the mutex
+func (p *pointsAccount) spendable(now time.Time) []*pointsEntry {
+	var entries []*pointsEntry
+	for _, entry := range p.Entries {
+		if entry.Remaining > 0 && entry.ExpiresAt != nil && entry.ExpiresAt.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	// Spend the points closest to expiry first
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ExpiresAt.Before(*entries[j].ExpiresAt)
+	})
+	return entries
+}
+
+// GetPoints returns the loyalty points balance and history of the user
+func (s *shoppingEngine) GetPoints(ctx context.Context, userId string) (*pointsView, error) {
+	user, err := s.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return user.Points.view(s.clock()), nil
+}
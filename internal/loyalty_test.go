@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test points are earned on orders with category multipliers
+func TestPlaceOrder_EarnsPoints(t *testing.T) {
+	shoppingApp := createMockEngine()
+	shoppingApp.Loyalty.Multipliers["books"] = 2
+
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	book, err := shoppingApp.RegisterProduct(context.Background(), "Book", "A book", 10, seller.Id, 20)
+	assert.NoError(t, err)
+	_, err = shoppingApp.SetProductCategory(context.Background(), book.Id, "books")
+	assert.NoError(t, err)
+	pen, err := shoppingApp.RegisterProduct(context.Background(), "Pen", "A pen", 10, seller.Id, 5.5)
+	assert.NoError(t, err)
+
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, book.Id, 1)
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, pen.Id, 1)
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 45, order.PointsEarned) // 20 * 2 + floor(5.5)
+	assert.Equal(t, 45, user.Points.Balance(time.Now()))
+}
+
+// Test points can be redeemed as a discount at checkout
+func TestCheckoutWithOptions_RedeemPoints(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+	user.Points.earn(PointsEarned, 1000, "previous-order", time.Now(), time.Hour)
+
+	// Act
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{RedeemPoints: 500})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 500, order.PointsRedeemed)
+	assert.Equal(t, 5.0, order.PointsDiscount)
+	assert.InDelta(t, order.CartTotal-5, order.AmountToPay, 1e-9)
+	assert.Equal(t, 500+order.PointsEarned, user.Points.Balance(time.Now()))
+}
+
+// Test redeeming more points than available fails
+func TestCheckoutWithOptions_InsufficientPoints(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	user.Points.earn(PointsEarned, 100, "previous-order", time.Now(), time.Hour)
+
+	// Act
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{RedeemPoints: 500})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, order)
+	assert.Equal(t, 10, p1.Quantity)
+	assert.Equal(t, 100, user.Points.Balance(time.Now()))
+}
+
+// Test expired points can't be spent
+func TestPointsAccount_Expiry(t *testing.T) {
+	account := newPointsAccount(generateUUID)
+	now := time.Now()
+	account.earn(PointsEarned, 100, "order-1", now.Add(-2*time.Hour), time.Hour)
+	account.earn(PointsEarned, 50, "order-2", now, time.Hour)
+
+	// Assert
+	assert.Equal(t, 50, account.Balance(now))
+	assert.Error(t, account.redeem(60, "order-3", now))
+	assert.NoError(t, account.redeem(50, "order-3", now))
+	assert.Equal(t, 0, account.Balance(now))
+}
+
+// Test cancelling an order reverses earned points and restores redeemed points
+func TestCancelOrder_ReversesPoints(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	user, p1 := createUserWithCart(t, shoppingApp)
+	user.Points.earn(PointsEarned, 300, "previous-order", time.Now(), time.Hour)
+
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{RedeemPoints: 300})
+	assert.NoError(t, err)
+	assert.Equal(t, order.PointsEarned, user.Points.Balance(time.Now()))
+
+	// Act
+	cancelled, err := shoppingApp.CancelOrder(context.Background(), user.Id, order.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusCancelled, cancelled.Status)
+	assert.Equal(t, 300, user.Points.Balance(time.Now()))
+	restored := user.Points.Entries[len(user.Points.Entries)-1]
+	assert.Equal(t, PointsRestored, restored.Type)
+	assert.Equal(t, *user.Points.Entries[0].ExpiresAt, *restored.ExpiresAt) // Restored points keep their original expiry
+	assert.Equal(t, 10, p1.Quantity)
+	payment, _ := gateway.Payment(order.PaymentId)
+	assert.InDelta(t, order.PaidByProvider, payment.Refunded, 1e-9)
+
+	// Act
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, order.Id)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+// Test cancelling an order whose points were already spent claws them back as a debt
+func TestCancelOrder_ClawsBackSpentPoints(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	first, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 1)
+	assert.NoError(t, err)
+	second, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{RedeemPoints: first.PointsEarned})
+	assert.NoError(t, err)
+
+	// Act
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, first.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, second.PointsEarned-first.PointsEarned, user.Points.Balance(time.Now()))
+	assert.Less(t, user.Points.Balance(time.Now()), 0)
+	assert.Error(t, user.Points.redeem(1, "order-3", time.Now()))
+
+	// Act
+	_, err = shoppingApp.CancelOrder(context.Background(), user.Id, second.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, user.Points.Balance(time.Now()))
+	assert.Equal(t, 0, user.Points.Debt)
+}
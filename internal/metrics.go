@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"github.com/ecommerce-store/utilities"
+)
+
+// recordCheckoutFailure counts a failed checkout of the engine's tenant by reason
+func (s *shoppingEngine) recordCheckoutFailure(reason string) {
+	utilities.Metrics.CheckoutFailures.WithLabelValues(s.Tenant, reason).Inc()
+}
+
+// WatchProduct adds the product to the products whose stock is exported as a metric
+func (s *shoppingEngine) WatchProduct(ctx context.Context, productId string) error {
+	// Check if product exists
+	_, err := s.GetProduct(ctx, productId)
+	if err != nil {
+		return err
+	}
+
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	s.Inventory.Watched[productId] = true
+	s.audit(ctx, AuditProductWatched, productId, nil, nil)
+	return nil
+}
+
+// UnwatchProduct stops exporting the stock of the product
+func (s *shoppingEngine) UnwatchProduct(ctx context.Context, productId string) {
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	delete(s.Inventory.Watched, productId)
+	s.audit(ctx, AuditProductUnwatched, productId, nil, nil)
+}
+
+// GetWatchedStock returns the current stock of every watched product
+func (s *shoppingEngine) GetWatchedStock(ctx context.Context) map[string]int {
+	// Stock is changed under the order lock
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	stock := make(map[string]int, len(s.Inventory.Watched))
+	for productId := range s.Inventory.Watched {
+		if product := s.Inventory.Products[productId]; product != nil {
+			stock[productId] = product.Quantity
+		}
+	}
+	return stock
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecommerce-store/utilities"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test checkout failures are counted by reason
+func TestCheckoutFailureMetrics(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, err := shoppingApp.RegisterUser(context.Background(), "Metrics", "metrics@example.com")
+	assert.NoError(t, err)
+	emptyCart := testutil.ToFloat64(utilities.Metrics.CheckoutFailures.WithLabelValues("", utilities.FailureEmptyCart))
+
+	// Act
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, emptyCart+1, testutil.ToFloat64(utilities.Metrics.CheckoutFailures.WithLabelValues("", utilities.FailureEmptyCart)))
+}
+
+// Test the business metrics of a tenant's engine are labelled with the tenant
+func TestCheckoutMetrics_Tenant(t *testing.T) {
+	shoppingApp := createMockEngine(WithTenant("acme"))
+	user, _ := createUserWithCart(t, shoppingApp)
+	orders := testutil.ToFloat64(utilities.Metrics.OrdersPlaced.WithLabelValues("acme"))
+	revenue := testutil.ToFloat64(utilities.Metrics.Revenue.WithLabelValues("acme"))
+	otherOrders := testutil.ToFloat64(utilities.Metrics.OrdersPlaced.WithLabelValues("globex"))
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, orders+1, testutil.ToFloat64(utilities.Metrics.OrdersPlaced.WithLabelValues("acme")))
+	assert.InDelta(t, revenue+order.AmountToPay, testutil.ToFloat64(utilities.Metrics.Revenue.WithLabelValues("acme")), 0.001)
+	assert.Equal(t, otherOrders, testutil.ToFloat64(utilities.Metrics.OrdersPlaced.WithLabelValues("globex")))
+}
+
+// Test only the stock of watched products is exported
+func TestWatchedStock(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+
+	// Act
+	err := shoppingApp.WatchProduct(context.Background(), p1.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{p1.Id: 10}, shoppingApp.GetWatchedStock(context.Background()))
+	assert.Error(t, shoppingApp.WatchProduct(context.Background(), "missing"))
+
+	// Act
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 8, shoppingApp.GetWatchedStock(context.Background())[p1.Id])
+
+	// Act
+	shoppingApp.UnwatchProduct(context.Background(), p1.Id)
+
+	// Assert
+	assert.Empty(t, shoppingApp.GetWatchedStock(context.Background()))
+}
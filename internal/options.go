@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Clock returns the current time
+type Clock func() time.Time
+
+// IDGenerator returns a new unique identifier
+type IDGenerator func() string
+
+// EngineOption customises an engine created by NewEngine
+type EngineOption func(*shoppingEngine)
+
+// WithClock sets the clock used to timestamp orders, ledger entries, audit entries and events
+func WithClock(clock Clock) EngineOption {
+	return func(s *shoppingEngine) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator sets the generator of user, product, order and ledger entry IDs
+func WithIDGenerator(newId IDGenerator) EngineOption {
+	return func(s *shoppingEngine) {
+		s.newId = newId
+	}
+}
+
+// WithStateStore sets the store the state is saved to, replacing the configured state file
+func WithStateStore(store StateStore) EngineOption {
+	return func(s *shoppingEngine) {
+		s.State = store
+	}
+}
+
+// WithDefaultLogger sets the logger used when the context doesn't carry one
+func WithDefaultLogger(logger *zap.Logger) EngineOption {
+	return func(s *shoppingEngine) {
+		s.log = logger
+	}
+}
+
+// WithTenant makes the engine serve a tenant, its logs are tagged with the tenant and it only restores state saved for the tenant
+func WithTenant(tenant string) EngineOption {
+	return func(s *shoppingEngine) {
+		s.Tenant = tenant
+	}
+}
+
+// WithCouponPolicy sets the initial coupon policy, replacing the configured discount interval
+func WithCouponPolicy(policy CouponPolicy) EngineOption {
+	return func(s *shoppingEngine) {
+		s.couponPolicy = policy
+	}
+}
+
+// WithPaymentProvider sets the gateway that authorizes and captures checkout payments, replacing the in-process fake
+func WithPaymentProvider(provider PaymentProvider) EngineOption {
+	return func(s *shoppingEngine) {
+		s.Payments = provider
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Test the clock and ID generator options stamp everything the engine creates
+func TestNewEngine_ClockAndIDs(t *testing.T) {
+	now := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC)
+	var next int
+	shoppingApp := createMockEngine(
+		WithClock(func() time.Time { return now }),
+		WithIDGenerator(func() string {
+			next++
+			return fmt.Sprintf("id-%d", next)
+		}),
+	)
+
+	// Act
+	user, p1 := createUserWithCart(t, shoppingApp)
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	wallet, err := shoppingApp.IssueStoreCredit(context.Background(), user.Id, 10, "goodwill")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, "id-3", user.Id)
+	assert.Equal(t, "id-2", p1.Id)
+	assert.Equal(t, "id-4", order.Id)
+	assert.Equal(t, now, order.CreatedAt)
+	assert.Equal(t, "id-6", wallet.Ledger[0].Id)
+	assert.Equal(t, now, wallet.Ledger[0].CreatedAt)
+	assert.Equal(t, "id-5", user.Points.Entries[0].Id)
+	assert.Equal(t, now, shoppingApp.Audit.Entries[0].Timestamp)
+	assert.Equal(t, now, shoppingApp.Events.history[0].CreatedAt)
+}
+
+// Test engines in the same process share no data
+func TestNewEngine_Isolated(t *testing.T) {
+	first := NewEngine(nil)
+	second := NewEngine(nil)
+	seller, err := first.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	product, err := first.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10.0)
+	assert.NoError(t, err)
+
+	// Act
+	_, registerErr := second.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	_, productErr := second.GetProduct(context.Background(), product.Id)
+
+	// Assert
+	assert.NoError(t, registerErr)
+	assert.ErrorIs(t, productErr, ErrNotFound)
+	assert.Empty(t, second.GetAuditLog(context.Background(), AuditFilter{}))
+}
+
+// Test the engine logs to its own logger unless the context carries one
+func TestNewEngine_DefaultLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	shoppingApp := createMockEngine(WithDefaultLogger(zap.New(core)))
+	requestCore, requestLogs := observer.New(zap.InfoLevel)
+
+	// Act
+	_, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.RegisterUser(WithLogger(context.Background(), zap.New(requestCore)), "Tejas", "tejas@example.com")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 1, logs.FilterMessageSnippet("aditya@example.com").Len())
+	assert.Equal(t, 0, logs.FilterMessageSnippet("tejas@example.com").Len())
+	assert.Equal(t, 1, requestLogs.FilterMessageSnippet("tejas@example.com").Len())
+}
+
+// recordingProvider is a PaymentProvider that records the operations it is asked for
+type recordingProvider struct {
+	operations []string
+}
+
+func (p *recordingProvider) Authorize(userId string, amount float64) (string, error) {
+	p.operations = append(p.operations, fmt.Sprintf("authorize %s %.2f", userId, amount))
+	return "ext-1", nil
+}
+
+func (p *recordingProvider) Capture(paymentId string, amount float64) error {
+	p.operations = append(p.operations, fmt.Sprintf("capture %s %.2f", paymentId, amount))
+	return nil
+}
+
+func (p *recordingProvider) Void(paymentId string) error {
+	p.operations = append(p.operations, "void "+paymentId)
+	return nil
+}
+
+func (p *recordingProvider) Refund(paymentId string, amount float64) error {
+	p.operations = append(p.operations, fmt.Sprintf("refund %s %.2f", paymentId, amount))
+	return nil
+}
+
+// Test checkout charges through the payment provider set by the option
+func TestNewEngine_PaymentProvider(t *testing.T) {
+	provider := &recordingProvider{}
+	shoppingApp := createMockEngine(WithPaymentProvider(provider))
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "ext-1", order.PaymentId)
+	assert.Equal(t, OrderStatusPaid, order.Status)
+	assert.Equal(t, []string{"authorize " + user.Id + " 199.98", "capture ext-1 199.98"}, provider.operations)
+}
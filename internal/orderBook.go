@@ -1,21 +1,33 @@
 package internal
 
 import (
-	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"context"
+	"sort"
 	"sync"
+	"time"
+	"github.com/ecommerce-store/utilities"
 )
 
 type OrderBook interface {
-	GetAnalytics() (int, float64, float64, []string)
+	GetAnalytics() (int, float64, float64, int)
+	GetTimeseries(from time.Time, to time.Time, interval string, location *time.Location) ([]*analyticsBucket, error)
+	GetTopProducts(from time.Time, to time.Time, limit int, by string) ([]*productRanking, error)
+	GetTopCustomers(from time.Time, to time.Time, limit int) []*customerRanking
+	GetCouponReport(from time.Time, to time.Time, limit int) []*couponStats
 }
 
 type orderBook struct {
 	ItemsSold         int               	// Total number of items sold
 	PurchaseAmount    float64           	// Total amount spent on all purchases
 	TotalDiscount     float64           	// Total discount amount applied
-	AppliedCoupons    []string          	// List of applied coupon codes
+	CouponRedemptions int                   // Number of orders that applied a coupon
 	Orders            map[string]*order 	// Map of all orders by orderId
 	OrdersByUserId    map[string][]*order 	// Map of orders by userId
+	OrdersByPaymentId map[string]*order     // Map of orders by paymentId
+	ProcessedEvents   map[string]bool       // IDs of payment webhook events already handled
+	Watchers          map[string][]*orderWatcher // Status watchers by orderId
+	Closed            bool                  // Set once the engine is closed, new watchers get the current status only
 	OrderMutex        *sync.Mutex       	// Mutex to prevent race conditions in order history
 	Counter           int               	// Counter for order numbering
 }
@@ -27,82 +39,293 @@ func newOrderBook() *orderBook {
 		OrderMutex: &sync.Mutex{},
 		Orders: make(map[string]*order), 
 		OrdersByUserId: make(map[string][]*order),
+		OrdersByPaymentId: make(map[string]*order),
+		ProcessedEvents: make(map[string]bool),
+		Watchers: make(map[string][]*orderWatcher),
 	}
 }
 
 // OrderHistory returns the current order book interface
-func (s *shoppingEngine) OrderHistory() OrderBook {
+func (s *shoppingEngine) OrderHistory(ctx context.Context) OrderBook {
 	return s.OrderBook
 }
 
 // PlaceOrder processes the order by adjusting inventory, updating the order book, and creating a new order
-func (s *shoppingEngine) PlaceOrder(userId string, amount float64, coupon string) (*order, error) {
+func (s *shoppingEngine) PlaceOrder(ctx context.Context, userId string, amount float64, options CheckoutOptions) (_ *order, err error) {
+	ctx, span := startSpan(ctx, "order.place")
+	defer func() { endSpan(span, err) }()
+
 	// Lock to ensure thread-safe operations on inventory and order history
 	s.OrderBook.OrderMutex.Lock()
 	defer s.OrderBook.OrderMutex.Unlock()
 
+	coupon := options.CouponCode
 	var discount float64
+	var couponPolicy string
 	// Apply coupon discount if a coupon is provided
 	if coupon != "" {
-		// Check if the coupon is valid based on a discount interval (e.g., every N orders)
-		if s.OrderBook.Counter % s.DiscountInterval != 0 {
-			Logger.Sugar().Debugf("Coupon has expired for user: %s", userId)
-			return nil, fmt.Errorf("Coupon has expired")
+		// Check if the user is still eligible under the coupon policy
+		if !s.isCouponEligible(ctx, userId) {
+			s.logger(ctx).Sugar().Debugf("Coupon has expired for user: %s", userId)
+			s.recordCheckoutFailure(utilities.FailureCouponExpired)
+			return nil, newError(ErrCouponExpired, "Coupon has expired")
 		}
 
 		if s.Coupons[userId] != coupon {
-			return nil, fmt.Errorf("Invalid coupon code")
+			s.recordCheckoutFailure(utilities.FailureInvalidCoupon)
+			return nil, newError(ErrCouponInvalid, "Invalid coupon code")
 		}
 		// Apply a 10% discount on the order total
 		discount = amount * 0.10
+		couponPolicy = s.GetCouponPolicy(ctx).Spec().Type
+	}
+
+	now := s.clock().UTC()
+	// Redeem loyalty points as an additional discount
+	var pointsDiscount float64
+	if options.RedeemPoints > 0 {
+		pointsDiscount = float64(options.RedeemPoints) * s.Loyalty.PointValue
+		if pointsDiscount > amount-discount {
+			s.recordCheckoutFailure(utilities.FailureOther)
+			return nil, newError(ErrValidation, "Redeemed points exceed the order amount")
+		}
+		if options.RedeemPoints > s.Users[userId].Points.Balance(now) {
+			s.recordCheckoutFailure(utilities.FailureOther)
+			return nil, newError(ErrValidation, "Insufficient loyalty points")
+		}
+	}
+
+	// Reserve the stock of every item in the user's cart
+	lineItems, processedItems, err := s.reserveStock(ctx, userId)
+	if err != nil {
+		s.recordCheckoutFailure(utilities.FailureOutOfStock)
+		return nil, err
+	}
+	distributeDiscount(lineItems, amount, discount+pointsDiscount)
+
+	// Calculate the final amount after applying the discounts
+	finalAmount := amount - discount - pointsDiscount
+
+	// Pay up to the final amount from the wallet if requested
+	walletAmount := options.WalletAmount
+	if walletAmount > finalAmount {
+		walletAmount = finalAmount
+	}
+	if walletAmount > s.Users[userId].Wallet.GetBalance() {
+		s.RollbackStock(ctx, userId, processedItems)
+		s.recordCheckoutFailure(utilities.FailureOther)
+		return nil, newError(ErrValidation, "Insufficient wallet balance")
+	}
+
+	// Authorize the rest while the stock is reserved, releasing the stock if the payment fails
+	paymentId, err := s.authorizePayment(ctx, userId, finalAmount-walletAmount)
+	if err != nil {
+		s.logger(ctx).Sugar().Debugf("Payment failed for user %s, rolling back the cart changes!", userId)
+		s.RollbackStock(ctx, userId, processedItems)
+		s.recordCheckoutFailure(utilities.FailurePaymentDeclined)
+
+		return nil, err
 	}
 
-	var processedItems []string
-	var items int
+	// Generate a new unique order ID and create the order object
+	id := s.newId()
+	order := newOrder(id, userId, lineItems, amount, coupon, discount, finalAmount, now)
+	order.CouponPolicy = couponPolicy
+	order.PaymentId = paymentId
+
+	// Undo the authorization and the stock reservation if the wallet or points can't be debited
+	rollback := func() {
+		if paymentId != "" {
+			if voidErr := s.Payments.Void(paymentId); voidErr != nil {
+				s.logger(ctx).Sugar().Errorf("Unable to void payment %s: %v", paymentId, voidErr)
+			}
+		}
+		s.RollbackStock(ctx, userId, processedItems)
+	}
+
+	// Debit the wallet share now that the rest of the payment is authorized
+	if walletAmount > 0 {
+		if _, err := s.Users[userId].Wallet.Debit(walletAmount, WalletReasonOrder, id); err != nil {
+			rollback()
+			s.recordCheckoutFailure(utilities.FailureOther)
+			return nil, err
+		}
+	}
+	order.PaidFromWallet = walletAmount
+	order.PaidByProvider = finalAmount - walletAmount
+
+	// Spend the redeemed points and award points for the purchase
+	if options.RedeemPoints > 0 {
+		if err := s.Users[userId].Points.redeem(options.RedeemPoints, id, now); err != nil {
+			if walletAmount > 0 {
+				s.Users[userId].Wallet.Credit(walletAmount, WalletReasonOrderRefund, id)
+			}
+			rollback()
+			s.recordCheckoutFailure(utilities.FailureOther)
+			return nil, err
+		}
+	}
+	order.PointsRedeemed = options.RedeemPoints
+	order.PointsDiscount = pointsDiscount
+
+	// Capture the authorized payment before the order is stored or announced, giving back what was spent if it's declined
+	if err := s.captureOrder(ctx, order); err != nil {
+		s.logger(ctx).Sugar().Debugf("Payment capture failed for user %s, rolling back the order!", userId)
+		if options.RedeemPoints > 0 {
+			s.Users[userId].Points.restoreRedeemed(id, now, s.Loyalty.Expiry)
+		}
+		if walletAmount > 0 {
+			s.Users[userId].Wallet.Credit(walletAmount, WalletReasonOrderRefund, id)
+		}
+		s.RollbackStock(ctx, userId, processedItems)
+		s.recordCheckoutFailure(utilities.FailurePaymentDeclined)
+		return nil, err
+	}
+
+	order.PointsEarned = s.Loyalty.pointsFor(lineItems)
+	s.Users[userId].Points.earn(PointsEarned, order.PointsEarned, id, now, s.Loyalty.Expiry)
+
+	// Clear the user's cart after placing the order
+	s.Users[userId].Cart = make(map[string]int)
+
+	// Store the order and update the sales counters
+	s.persistOrder(ctx, order)
+	s.publishOrderPlaced(order)
+	s.publishStock(order.Items)
+
+	utilities.Metrics.OrdersPlaced.WithLabelValues(s.Tenant).Inc()
+	utilities.Metrics.Revenue.WithLabelValues(s.Tenant).Add(finalAmount)
+
+	s.logger(ctx).Sugar().Infof("Order placed successfully with id: %s by user: %s", id, userId)
+	return order, nil
+}
+
+// reserveStock removes the items in the user's cart from stock, rolling back if any product is out of stock
+func (s *shoppingEngine) reserveStock(ctx context.Context, userId string) (lineItems []*lineItem, processedItems []string, err error) {
+	ctx, span := startSpan(ctx, "stock.deduct")
+	defer func() { endSpan(span, err) }()
 
 	// Iterate through each item in the user's cart to adjust stock
 	for key, value := range s.Users[userId].Cart {
 		if !s.Inventory.Products[key].RemoveFromStock(value) {
 			// Rollback any stock changes if a product is out of stock
-			Logger.Sugar().Debugf("Product %s is out of stock, rolling back the cart changes!", key)
-			s.RollbackStock(userId, processedItems)
+			s.logger(ctx).Sugar().Debugf("Product %s is out of stock, rolling back the cart changes!", key)
+			s.RollbackStock(ctx, userId, processedItems)
 
-			return nil, fmt.Errorf("Product %s is out of stock", key)
+			return nil, nil, newError(ErrInsufficientStock, "Product %s is out of stock", key)
 		}
 		// Track processed items for rollback if needed
 		processedItems = append(processedItems, key)
-		items += value
+		// Snapshot the product as it is at the time of purchase
+		lineItems = append(lineItems, newLineItem(s.Inventory.Products[key], value))
 	}
 
-	// Calculate the final amount after applying the discount
-	finalAmount := amount - discount
+	// Keep line items in a stable order for receipts
+	sort.Slice(lineItems, func(i, j int) bool {
+		return lineItems[i].ProductId < lineItems[j].ProductId
+	})
+	span.SetAttributes(attribute.Int("order.line_items", len(lineItems)))
+	return lineItems, processedItems, nil
+}
 
-	// Generate a new unique order ID and create the order object
-	id := generateUUID()
-	order := newOrder(id, userId, s.Users[userId].Cart, amount, coupon, discount, finalAmount)
+// persistOrder stores the order in the order book and updates the sales counters, the caller must hold the OrderMutex
+func (s *shoppingEngine) persistOrder(ctx context.Context, order *order) {
+	_, span := startSpan(ctx, "order.persist", attribute.String("order.id", order.Id))
+	defer span.End()
 
 	// Store the newly created order in the order book
-	s.OrderBook.Orders[id] = order
-	s.OrderBook.OrdersByUserId[userId] = append(s.OrderBook.OrdersByUserId[userId], order)
+	s.OrderBook.Orders[order.Id] = order
+	s.OrderBook.OrdersByUserId[order.UserId] = append(s.OrderBook.OrdersByUserId[order.UserId], order)
+	if order.PaymentId != "" {
+		s.OrderBook.OrdersByPaymentId[order.PaymentId] = order
+	}
 	s.OrderBook.Counter++ // Increment the order counter
 
-	// Clear the user's cart after placing the order
-	s.Users[userId].Cart = make(map[string]int)
-
 	// Update the order book
-	s.OrderBook.ItemsSold += items            
-	s.OrderBook.PurchaseAmount += finalAmount
-	s.OrderBook.TotalDiscount += discount
-	if coupon != "" {
-		s.OrderBook.AppliedCoupons = append(s.OrderBook.AppliedCoupons, coupon)
+	for _, item := range order.Items {
+		s.OrderBook.ItemsSold += item.Quantity
+	}
+	s.OrderBook.PurchaseAmount += order.AmountToPay
+	s.OrderBook.TotalDiscount += order.Discount
+	if order.DiscountCoupon != "" {
+		s.OrderBook.CouponRedemptions++
+	}
+}
+
+// CancelOrder cancels a paid order, refunding the user and returning the stock
+func (s *shoppingEngine) CancelOrder(ctx context.Context, userId string, orderId string) (*order, error) {
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	// Users may only cancel their own orders
+	order := s.OrderBook.Orders[orderId]
+	if order == nil || order.UserId != userId {
+		return nil, newError(ErrNotFound, "Order not found")
+	}
+	if order.Status != OrderStatusPaid && order.Status != OrderStatusAuthorized {
+		return nil, newError(ErrConflict, "Order with status %s can't be cancelled", order.Status)
 	}
 
-	Logger.Sugar().Infof("Order placed successfully with id: %s by user: %s", id, userId)
+	// Refund the part paid through the payment provider, or void it if it was never captured
+	if order.PaymentId != "" && order.PaidByProvider > 0 {
+		release := func() error { return s.Payments.Refund(order.PaymentId, order.PaidByProvider) }
+		if order.Status == OrderStatusAuthorized {
+			release = func() error { return s.Payments.Void(order.PaymentId) }
+		}
+		if err := release(); err != nil {
+			return nil, err
+		}
+	}
+	s.releaseOrder(ctx, order, true)
+
+	order.Status = OrderStatusCancelled
+	order.StatusUpdatedAt = s.clock().UTC()
+	s.notifyOrderStatus(ctx, order)
+
+	s.logger(ctx).Sugar().Infof("Order %s cancelled by user: %s", orderId, userId)
 	return order, nil
 }
 
+// releaseOrder gives back the wallet funds and points spent on an order and takes back the points it earned.
+// The caller must hold the OrderMutex.
+func (s *shoppingEngine) releaseOrder(ctx context.Context, order *order, restock bool) {
+	// Only orders that still stand count towards the sales analytics
+	for _, item := range order.Items {
+		s.OrderBook.ItemsSold -= item.Quantity
+	}
+	s.OrderBook.PurchaseAmount -= order.AmountToPay
+	s.OrderBook.TotalDiscount -= order.Discount
+	if order.DiscountCoupon != "" {
+		s.OrderBook.CouponRedemptions--
+	}
+
+	// Return the reserved stock
+	if restock {
+		for _, item := range order.Items {
+			if s.Inventory.Products[item.ProductId] != nil {
+				s.Inventory.Products[item.ProductId].AddToStock(item.Quantity)
+			}
+		}
+		s.publishStock(order.Items)
+	}
+
+	user := s.Users[order.UserId]
+	if user == nil {
+		return
+	}
+	if order.PaidFromWallet > 0 {
+		if _, err := user.Wallet.Credit(order.PaidFromWallet, WalletReasonOrderRefund, order.Id); err != nil {
+			s.logger(ctx).Sugar().Errorf("Unable to return wallet funds for order %s: %v", order.Id, err)
+		}
+	}
+
+	now := s.clock().UTC()
+	user.Points.reverse(order.Id, now)
+	user.Points.restoreRedeemed(order.Id, now, s.Loyalty.Expiry)
+}
+
 // RollbackStock reverts the stock changes for the specified products in the user's cart
-func (s *shoppingEngine) RollbackStock(userId string, products []string) {
+func (s *shoppingEngine) RollbackStock(ctx context.Context, userId string, products []string) {
 	// Rollback all changes made during the cart validation process
 	for _, productId := range products {
 		// Add back the quantity of each product to the stock
@@ -113,9 +336,9 @@ func (s *shoppingEngine) RollbackStock(userId string, products []string) {
 }
 
 // GetAnalytics returns the information about analytics related to orders
-func (o *orderBook) GetAnalytics() (int, float64, float64, []string) {
+func (o *orderBook) GetAnalytics() (int, float64, float64, int) {
 	o.OrderMutex.Lock()
 	defer o.OrderMutex.Unlock()
 
-	return o.ItemsSold, o.PurchaseAmount, o.TotalDiscount, o.AppliedCoupons
+	return o.ItemsSold, o.PurchaseAmount, o.TotalDiscount, o.CouponRedemptions
 }
\ No newline at end of file
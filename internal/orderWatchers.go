@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// Status updates buffered per watcher, an order changes status only a handful of times
+const orderWatchBuffer = 8
+
+// OrderStatusUpdate is sent to the watchers of an order whenever its status changes
+type OrderStatusUpdate struct {
+	OrderId     string        `json:"order_id"`     // Order whose status changed
+	Status      orderStatus   `json:"status"`       // New status of the order
+	UpdatedAt   time.Time     `json:"updated_at"`   // Time of the status change
+}
+
+// orderWatcher receives the status updates of a single order
+type orderWatcher struct {
+	Updates   chan OrderStatusUpdate   // Updates in the order they happened
+	done      chan struct{}            // Closed once the watcher is removed
+}
+
+// isFinal reports whether the order can't change status anymore
+func (o *order) isFinal() bool {
+	return len(allowedTransitions[o.Status]) == 0
+}
+
+// statusUpdate returns the current status of the order as an update
+func (o *order) statusUpdate() OrderStatusUpdate {
+	return OrderStatusUpdate{OrderId: o.Id, Status: o.Status, UpdatedAt: o.StatusUpdatedAt}
+}
+
+// GetOrder returns an order placed by the user
+func (s *shoppingEngine) GetOrder(ctx context.Context, userId string, orderId string) (*order, error) {
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	// Users may only see their own orders
+	order := s.OrderBook.Orders[orderId]
+	if order == nil || order.UserId != userId {
+		return nil, newError(ErrNotFound, "Order not found")
+	}
+	return order, nil
+}
+
+// GetOrders returns the orders placed by the user, oldest first
+func (s *shoppingEngine) GetOrders(ctx context.Context, userId string) ([]*order, error) {
+	if _, err := s.GetUser(ctx, userId); err != nil {
+		return nil, err
+	}
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	// Copy so later orders don't race with the caller
+	return append([]*order{}, s.OrderBook.OrdersByUserId[userId]...), nil
+}
+
+// WatchOrderStatus returns a channel receiving the current status of the user's order followed by every change.
+// The channel is closed once the order reaches a final status, ctx is done or the engine is closed.
+func (s *shoppingEngine) WatchOrderStatus(ctx context.Context, userId string, orderId string) (<-chan OrderStatusUpdate, error) {
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	order := s.OrderBook.Orders[orderId]
+	if order == nil || order.UserId != userId {
+		return nil, newError(ErrNotFound, "Order not found")
+	}
+
+	watcher := &orderWatcher{
+		Updates: make(chan OrderStatusUpdate, orderWatchBuffer),
+		done:    make(chan struct{}),
+	}
+	watcher.Updates <- order.statusUpdate()
+	if order.isFinal() || s.OrderBook.Closed {
+		close(watcher.Updates)
+		return watcher.Updates, nil
+	}
+	s.OrderBook.Watchers[orderId] = append(s.OrderBook.Watchers[orderId], watcher)
+
+	// Stop watching when the caller goes away
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.OrderBook.OrderMutex.Lock()
+			s.removeOrderWatcher(orderId, watcher)
+			s.OrderBook.OrderMutex.Unlock()
+		case <-watcher.done:
+		}
+	}()
+	return watcher.Updates, nil
+}
+
+// notifyOrderStatus sends the new status of the order to its watchers and publishes it, the caller must hold the OrderMutex
+func (s *shoppingEngine) notifyOrderStatus(ctx context.Context, order *order) {
+	s.publishOrderStatus(order)
+	update := order.statusUpdate()
+	for _, watcher := range s.OrderBook.Watchers[order.Id] {
+		select {
+		case watcher.Updates <- update:
+		default:
+			s.logger(ctx).Sugar().Warnf("Dropping status update for order %s, watcher isn't keeping up", order.Id)
+		}
+	}
+	// Nothing follows a final status
+	if order.isFinal() {
+		for len(s.OrderBook.Watchers[order.Id]) > 0 {
+			s.removeOrderWatcher(order.Id, s.OrderBook.Watchers[order.Id][0])
+		}
+	}
+}
+
+// removeOrderWatcher closes the watcher's channel, the caller must hold the OrderMutex
+func (s *shoppingEngine) removeOrderWatcher(orderId string, watcher *orderWatcher) {
+	watchers := s.OrderBook.Watchers[orderId]
+	for i, w := range watchers {
+		if w == watcher {
+			s.OrderBook.Watchers[orderId] = append(watchers[:i], watchers[i+1:]...)
+			close(watcher.Updates)
+			close(watcher.done)
+			break
+		}
+	}
+	if len(s.OrderBook.Watchers[orderId]) == 0 {
+		delete(s.OrderBook.Watchers, orderId)
+	}
+}
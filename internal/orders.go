@@ -1,25 +1,112 @@
 package internal
 
+import (
+	"time"
+)
+
 // order represents an order placed by a user
 type order struct {
 	Id              string           	`json:"id"`              	// Unique order ID
 	UserId          string          	`json:"user_id"`         	// User ID who placed the order
 	OrderCart       map[string]int  	`json:"order_cart"`      	// Cart with product IDs and quantities
+	Items           []*lineItem         `json:"items"`              // Snapshot of the purchased products
 	CartTotal     	float64             `json:"amount"`           	// Total cart value before discount
 	Discount        float64            	`json:"discount"`        	// Discount applied on the order
 	DiscountCoupon  string            	`json:"discount_coupon"` 	// Applied coupon code
+	CouponPolicy    string              `json:"coupon_policy,omitempty"` // Coupon policy in effect when the coupon was applied
 	AmountToPay     float64          	`json:"amount_to_pay"`    	// Final amount after discount
+	PaidFromWallet  float64             `json:"paid_from_wallet"`   // Part of AmountToPay paid from the wallet
+	PaidByProvider  float64             `json:"paid_by_provider"`   // Part of AmountToPay charged by the payment provider
+	PointsRedeemed  int                 `json:"points_redeemed"`    // Loyalty points spent on the order
+	PointsDiscount  float64             `json:"points_discount"`    // Discount given for the redeemed points
+	PointsEarned    int                 `json:"points_earned"`      // Loyalty points awarded for the order
+	PaymentId       string              `json:"payment_id"`         // Payment ID returned by the payment provider
+	Status          orderStatus         `json:"status"`             // Payment status of the order
+	CreatedAt       time.Time           `json:"created_at"`         // Time at which the order was placed
+	StatusUpdatedAt time.Time           `json:"status_updated_at"`  // Time of the last status change
+	PaymentEventAt  time.Time           `json:"payment_event_at,omitempty"` // Provider time of the last payment event applied
+}
+
+// lineItem is an immutable snapshot of a product at the time it was ordered
+type lineItem struct {
+	ProductId       string              `json:"product_id"`         // Product ID at the time of purchase
+	Name            string              `json:"name"`               // Product name at the time of purchase
+	SellerId        string              `json:"seller_id"`          // Seller who sold the product
+	Category        string              `json:"category"`           // Product category at the time of purchase
+	UnitPrice       float64             `json:"unit_price"`         // Price of a single unit
+	Quantity        int                 `json:"quantity"`           // Number of units purchased
+	LineTotal       float64             `json:"line_total"`         // UnitPrice * Quantity
+	Discount        float64             `json:"discount"`           // Share of the order discount applied to this line
 }
 
 // newOrder creates a new order instance
-func newOrder(id string, userId string, cart map[string]int, amount float64, coupon string, discount float64, finalAmount float64) *order {
+func newOrder(id string, userId string, items []*lineItem, amount float64, coupon string, discount float64, finalAmount float64, now time.Time) *order {
+	// Keep a private copy of the cart so later cart changes don't leak into the order
+	cart := make(map[string]int, len(items))
+	for _, item := range items {
+		cart[item.ProductId] = item.Quantity
+	}
 	return &order{
 		Id:             id,               // Set unique order ID
 		UserId:         userId,           // Set user ID
 		OrderCart:      cart,             // Set order cart with product quantities
+		Items:          items,            // Set product snapshots
 		CartTotal:      amount,           // Set total cart amount before discount
 		DiscountCoupon: coupon,           // Set applied coupon code
 		Discount:       discount,         // Set discount applied on the order
 		AmountToPay:    finalAmount,      // Set final amount after discount
+		Status:         OrderStatusAuthorized, // Orders start out authorized
+		CreatedAt:      now,              // Set order creation time
+		StatusUpdatedAt: now,             // Status is set at creation
+	}
+}
+
+// clone returns a copy of the order that later changes to the order don't show in
+func (o *order) clone() *order {
+	copied := *o
+	copied.OrderCart = make(map[string]int, len(o.OrderCart))
+	for productId, quantity := range o.OrderCart {
+		copied.OrderCart[productId] = quantity
+	}
+	copied.Items = make([]*lineItem, len(o.Items))
+	for i, item := range o.Items {
+		itemCopy := *item
+		copied.Items[i] = &itemCopy
+	}
+	return &copied
+}
+
+// isActive reports whether the order still stands, i.e. it wasn't cancelled, refunded or failed
+func (o *order) isActive() bool {
+	return o.Status != OrderStatusCancelled && o.Status != OrderStatusRefunded && o.Status != OrderStatusFailed
+}
+
+// newLineItem captures the current state of a product for an order
+func newLineItem(p *product, quantity int) *lineItem {
+	return &lineItem{
+		ProductId: p.Id,
+		Name:      p.Name,
+		SellerId:  p.SellerId,
+		Category:  p.Category,
+		UnitPrice: p.Price,
+		Quantity:  quantity,
+		LineTotal: p.Price * float64(quantity),
 	}
-}
\ No newline at end of file
+}
+
+// distributeDiscount splits the order discount across line items in proportion to their totals
+func distributeDiscount(items []*lineItem, amount float64, discount float64) {
+	if amount <= 0 || discount <= 0 || len(items) == 0 {
+		return
+	}
+	remaining := discount
+	for i, item := range items {
+		// The last line absorbs rounding so the shares always add up to the discount
+		if i == len(items)-1 {
+			item.Discount = remaining
+			break
+		}
+		item.Discount = discount * item.LineTotal / amount
+		remaining -= item.Discount
+	}
+}
@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test order keeps a snapshot of products after the product changes
+func TestPlaceOrder_ProductSnapshot(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	// Register a seller and a product
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Nina", "nina@example.com")
+	assert.NoError(t, err)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 50.0)
+	assert.NoError(t, err)
+
+	// Register a user and fill the cart
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 2)
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, order.CreatedAt.IsZero())
+	assert.Len(t, order.Items, 1)
+
+	// Rename and reprice the product after the order was placed
+	p1.Name = "Renamed product"
+	p1.Price = 75.0
+
+	item := order.Items[0]
+	assert.Equal(t, p1.Id, item.ProductId)
+	assert.Equal(t, "Product 1", item.Name)
+	assert.Equal(t, seller.Id, item.SellerId)
+	assert.Equal(t, 50.0, item.UnitPrice)
+	assert.Equal(t, 2, item.Quantity)
+	assert.Equal(t, 100.0, item.LineTotal)
+	assert.Equal(t, 0.0, item.Discount)
+}
+
+// Test order cart is not shared with the user's cart
+func TestPlaceOrder_CartNotAliased(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	// Register a seller and a product
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Omar", "omar@example.com")
+	assert.NoError(t, err)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10.0)
+	assert.NoError(t, err)
+
+	// Register a user and fill the cart
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	cart, err := shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 3)
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+
+	// Mutate the map the user used before checkout
+	cart[p1.Id] = 100
+
+	// Assert
+	assert.Equal(t, map[string]int{p1.Id: 3}, order.OrderCart)
+}
+
+// Test the order discount is split across line items
+func TestPlaceOrder_DiscountShares(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	// Register a seller and two products
+	seller, err := shoppingApp.RegisterUser(context.Background(), "Lea", "lea@example.com")
+	assert.NoError(t, err)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 100.0)
+	assert.NoError(t, err)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 10, seller.Id, 300.0)
+	assert.NoError(t, err)
+
+	// Place a first order so that the next one is eligible for a coupon
+	user, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 1)
+	assert.NoError(t, err)
+	_, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p1.Id, 1)
+	assert.NoError(t, err)
+	_, err = shoppingApp.AddToCart(context.Background(), user.Id, p2.Id, 1)
+	assert.NoError(t, err)
+	coupon, err := shoppingApp.GetDiscountCoupon(context.Background(), user.Id)
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, coupon)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, order.Items, 2)
+	var total float64
+	for _, item := range order.Items {
+		assert.InDelta(t, item.LineTotal*0.10, item.Discount, 1e-9)
+		total += item.Discount
+	}
+	assert.InDelta(t, order.Discount, total, 1e-9)
+}
+
+// Test watchers receive the current status and every change until the order is final
+func TestWatchOrderStatus_UntilFinal(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	// Act
+	updates, err := shoppingApp.WatchOrderStatus(context.Background(), order.UserId, order.Id)
+	assert.NoError(t, err)
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventDisputed, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature))
+	_, err = shoppingApp.CancelOrder(context.Background(), order.UserId, order.Id)
+	assert.Error(t, err) // Disputed orders can't be cancelled
+	payload, signature = signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_2", Type: PaymentEventRefunded, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature))
+
+	// Assert
+	var statuses []orderStatus
+	for update := range updates {
+		assert.Equal(t, order.Id, update.OrderId)
+		statuses = append(statuses, update.Status)
+	}
+	assert.Equal(t, []orderStatus{OrderStatusPaid, OrderStatusDisputed, OrderStatusRefunded}, statuses)
+	assert.Empty(t, shoppingApp.OrderBook.Watchers)
+}
+
+// Test watchers are removed when their context is done
+func TestWatchOrderStatus_ContextDone(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Act
+	updates, err := shoppingApp.WatchOrderStatus(ctx, order.UserId, order.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPaid, (<-updates).Status)
+	cancel()
+
+	// Assert
+	_, open := <-updates
+	assert.False(t, open)
+	shoppingApp.OrderBook.OrderMutex.Lock()
+	assert.Empty(t, shoppingApp.OrderBook.Watchers)
+	shoppingApp.OrderBook.OrderMutex.Unlock()
+}
+
+// Test users can't get or watch orders of other users
+func TestGetOrder_OtherUser(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+	other, err := shoppingApp.RegisterUser(context.Background(), "Mara", "mara@example.com")
+	assert.NoError(t, err)
+
+	// Act
+	_, getErr := shoppingApp.GetOrder(context.Background(), other.Id, order.Id)
+	_, watchErr := shoppingApp.WatchOrderStatus(context.Background(), other.Id, order.Id)
+
+	// Assert
+	assert.ErrorIs(t, getErr, ErrNotFound)
+	assert.ErrorIs(t, watchErr, ErrNotFound)
+	found, err := shoppingApp.GetOrder(context.Background(), order.UserId, order.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, order, found)
+}
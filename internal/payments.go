@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"context"
+	"errors"
+)
+
+// ErrPaymentDeclined is returned when the payment provider refuses a payment operation
+var ErrPaymentDeclined = errors.New("Payment declined")
+
+// PaymentProvider is the gateway used to charge users at checkout
+type PaymentProvider interface {
+	// Authorize reserves the amount on the user's payment method and returns a payment ID
+	Authorize(userId string, amount float64) (string, error)
+	// Capture collects a previously authorized amount
+	Capture(paymentId string, amount float64) error
+	// Void releases an authorization that was not captured
+	Void(paymentId string) error
+	// Refund returns a captured amount to the user
+	Refund(paymentId string, amount float64) error
+}
+
+// paymentStateHolder is implemented by providers that keep their payments in the process, which are saved with the engine state
+type paymentStateHolder interface {
+	// paymentState returns a copy of the payments and the numbering
+	paymentState() *PaymentState
+	// restorePayments replaces the payments and the numbering with saved ones
+	restorePayments(state *PaymentState)
+}
+
+// PaymentState is the saved state of an in-process payment provider
+type PaymentState struct {
+	Counter    int              `json:"counter"`     // Number of the last payment
+	Payments   []fakePayment    `json:"payments"`    // Every payment taken
+}
+
+// orderStatus describes where an order is in its payment lifecycle
+type orderStatus string
+
+const (
+	OrderStatusAuthorized orderStatus = "authorized" // Payment authorized, not yet captured
+	OrderStatusPaid       orderStatus = "paid"       // Payment captured
+	OrderStatusFailed     orderStatus = "failed"     // Payment failed after the order was placed
+	OrderStatusRefunded   orderStatus = "refunded"   // Payment returned to the user
+	OrderStatusDisputed   orderStatus = "disputed"   // Payment disputed by the user
+	OrderStatusCancelled  orderStatus = "cancelled"  // Order cancelled and refunded
+)
+
+// chargeOrder authorizes and captures the amount in one go, for purchases that have no order to track the payment
+func (s *shoppingEngine) chargeOrder(ctx context.Context, userId string, amount float64) (string, error) {
+	paymentId, err := s.authorizePayment(ctx, userId, amount)
+	if err != nil || paymentId == "" {
+		return "", err
+	}
+	if err := s.capturePayment(ctx, paymentId, amount); err != nil {
+		return "", err
+	}
+	return paymentId, nil
+}
+
+// authorizePayment reserves the amount on the user's payment method, returning no payment ID when there is nothing to charge
+func (s *shoppingEngine) authorizePayment(ctx context.Context, userId string, amount float64) (_ string, err error) {
+	ctx, span := startSpan(ctx, "payment.authorize", attribute.Float64("payment.amount", amount))
+	defer func() { endSpan(span, err) }()
+
+	// Nothing to charge for free orders
+	if amount <= 0 {
+		return "", nil
+	}
+
+	paymentId, err := s.Payments.Authorize(userId, amount)
+	if err != nil {
+		s.logger(ctx).Sugar().Debugf("Payment authorization failed for user %s: %v", userId, err)
+		return "", err
+	}
+	return paymentId, nil
+}
+
+// capturePayment collects an authorized amount, voiding the authorization if capture fails
+func (s *shoppingEngine) capturePayment(ctx context.Context, paymentId string, amount float64) (err error) {
+	ctx, span := startSpan(ctx, "payment.capture", attribute.String("payment.id", paymentId), attribute.Float64("payment.amount", amount))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.Payments.Capture(paymentId, amount); err != nil {
+		s.logger(ctx).Sugar().Debugf("Payment capture failed for payment %s: %v", paymentId, err)
+		// Release the authorization so the user isn't charged
+		if voidErr := s.Payments.Void(paymentId); voidErr != nil {
+			s.logger(ctx).Sugar().Errorf("Unable to void payment %s: %v", paymentId, voidErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// captureOrder moves an authorized order to paid once its payment is captured, the authorization is voided if capture fails.
+// Orders are captured before they are stored, so a failed capture leaves nothing to undo in the order book.
+func (s *shoppingEngine) captureOrder(ctx context.Context, order *order) error {
+	if order.PaymentId != "" {
+		if err := s.capturePayment(ctx, order.PaymentId, order.PaidByProvider); err != nil {
+			return err
+		}
+	}
+	order.Status = OrderStatusPaid
+	order.StatusUpdatedAt = s.clock().UTC()
+	return nil
+}
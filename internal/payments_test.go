@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Checkout captures the payment for the order
+func TestCheckout_PaymentCaptured(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPaid, order.Status)
+	payment, ok := gateway.Payment(order.PaymentId)
+	assert.True(t, ok)
+	assert.Equal(t, user.Id, payment.UserId)
+	assert.Equal(t, order.AmountToPay, payment.Captured)
+}
+
+// Test Checkout rolls back the stock when authorization is declined
+func TestCheckout_AuthorizationDeclined(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	user, p1 := createUserWithCart(t, shoppingApp)
+	gateway.DeclineNext(PaymentAuthorize, "insufficient funds")
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.Nil(t, order)
+	assert.Equal(t, 10, p1.Quantity)
+	assert.Empty(t, shoppingApp.OrderBook.Orders)
+	assert.Equal(t, 2, user.Cart[p1.Id]) // Cart is kept so the user can retry
+}
+
+// Test Checkout voids the authorization when capture is declined
+func TestCheckout_CaptureDeclined(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	user, p1 := createUserWithCart(t, shoppingApp)
+	gateway.DeclineNext(PaymentCapture, "processor unavailable")
+
+	// Act
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.Nil(t, order)
+	assert.Equal(t, 10, p1.Quantity)
+	assert.Empty(t, shoppingApp.OrderBook.Orders)
+	assert.Equal(t, 2, user.Cart[p1.Id])
+	items, amount, discount, coupons := shoppingApp.OrderBook.GetAnalytics()
+	assert.Equal(t, 0, items)
+	assert.Equal(t, 0.0, amount)
+	assert.Equal(t, 0.0, discount)
+	assert.Equal(t, 0, coupons)
+	payment, ok := gateway.Payment("pay_1")
+	assert.True(t, ok)
+	assert.True(t, payment.Voided)
+
+	// Retry succeeds once the gateway recovers
+	order, err = shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pay_2", order.PaymentId)
+}
+
+// Test FakeGateway enforces authorized and captured amounts
+func TestFakeGateway_Limits(t *testing.T) {
+	gateway := NewFakeGateway()
+
+	// Act
+	id, err := gateway.Authorize("user", 100)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Error(t, gateway.Capture(id, 150))
+	assert.NoError(t, gateway.Capture(id, 100))
+	assert.Error(t, gateway.Void(id))
+	assert.NoError(t, gateway.Refund(id, 40))
+	assert.Error(t, gateway.Refund(id, 70))
+	assert.NoError(t, gateway.Refund(id, 60))
+
+	payment, _ := gateway.Payment(id)
+	assert.Equal(t, 100.0, payment.Refunded)
+}
@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"fmt"
+	"context"
 )
 
 // product represents a single product in the inventory
@@ -12,18 +12,21 @@ type product struct {
 	Quantity        int     `json:"quantity"` 		// Available stock quantity
 	Price           float64 `json:"price"` 			// Price of the product
 	SellerId        string  `json:"seller_id"` 		// Seller's unique identifier
+	Category        string  `json:"category"`        // Category used for loyalty multipliers
 }
 
 // inventory manages the collection of products and their categorization by seller
 type inventory struct {
 	Products   			map[string]*product       // Mapping of product IDs to products
 	ProductsBySeller 	map[string][]*product     // Mapping of seller IDs to their products
+	Watched             map[string]bool           // Products whose stock is exported as a metric
 }
 
 func newInventory() *inventory {
 	return &inventory{
 		Products: make(map[string]*product), 
 		ProductsBySeller: make(map[string][]*product),
+		Watched: make(map[string]bool),
 	}
 }
 
@@ -40,9 +43,9 @@ func newProduct(id string, name string, description string, quantity int, seller
 }
 
 // RegisterProduct adds a new product to the inventory if the seller is valid and the product doesn't already exist
-func (s *shoppingEngine) RegisterProduct(name string, description string, quantity int, sellerId string, price float64) (*product, error) {
+func (s *shoppingEngine) RegisterProduct(ctx context.Context, name string, description string, quantity int, sellerId string, price float64) (*product, error) {
 	// Ensure the seller is valid
-	_, err := s.GetUser(sellerId)
+	_, err := s.GetUser(ctx, sellerId)
 	if err != nil {
 		return nil, err // Return error if seller does not exist
 	}
@@ -50,34 +53,59 @@ func (s *shoppingEngine) RegisterProduct(name string, description string, quanti
 	// Check if product already exists for the seller
 	for _, product := range s.Inventory.ProductsBySeller[sellerId] {
 		if product.Name == name {
-			return nil, fmt.Errorf("product with name already exists by the seller") // Error if the product already exists
+			return nil, newError(ErrConflict, "product with name already exists by the seller") // Error if the product already exists
 		}
 	}
 
 	// Generate unique product ID and create the new product
-	id := generateUUID()
+	id := s.newId()
 	product := newProduct(id, name, description, quantity, sellerId, price)
 
 	// Add product to the seller's inventory and global inventory
 	s.Inventory.ProductsBySeller[sellerId] = append(s.Inventory.ProductsBySeller[sellerId], product)
 	s.Inventory.Products[id] = product
 
-	Logger.Sugar().Infof("Product %s registered successfully", id)
+	s.audit(ctx, AuditProductRegistered, id, nil, product)
+	s.logger(ctx).Sugar().Infof("Product %s registered successfully", id)
 	return product, nil
 }
 
 // GetProduct fetches a product by its ID from the inventory
-func (s *shoppingEngine) GetProduct(productId string) (*product, error) {
+func (s *shoppingEngine) GetProduct(ctx context.Context, productId string) (*product, error) {
 	if s.Inventory.Products[productId] == nil {
-		return nil, fmt.Errorf("Product not found")
+		return nil, newError(ErrNotFound, "Product not found")
 	}
 	return s.Inventory.Products[productId], nil
 }
 
-func (s *shoppingEngine) RemoveProduct(productId string) error {
+// GetProducts fetches several products at once, leaving out IDs that don't match a product
+func (s *shoppingEngine) GetProducts(ctx context.Context, productIds []string) map[string]*product {
+	products := make(map[string]*product, len(productIds))
+	for _, id := range productIds {
+		if product := s.Inventory.Products[id]; product != nil {
+			products[id] = product
+		}
+	}
+	return products
+}
+
+// SetProductCategory assigns a category to a product
+func (s *shoppingEngine) SetProductCategory(ctx context.Context, productId string, category string) (*product, error) {
+	product, err := s.GetProduct(ctx, productId)
+	if err != nil {
+		return nil, err
+	}
+	before := product.Category
+	product.Category = category
+	s.audit(ctx, AuditProductCategorySet, productId, map[string]string{"category": before}, map[string]string{"category": category})
+	return product, nil
+}
+
+func (s *shoppingEngine) RemoveProduct(ctx context.Context, productId string) error {
 	if s.Inventory.Products[productId] == nil {
-		return fmt.Errorf("Product not found")
+		return newError(ErrNotFound, "Product not found")
 	}
+	s.audit(ctx, AuditProductRemoved, productId, s.Inventory.Products[productId], nil)
 	s.Inventory.Products[productId] = nil
 	return nil
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"testing"
 	"github.com/stretchr/testify/assert"
 )
@@ -10,10 +11,10 @@ func TestRegisterProduct_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller user
-	seller, _ := shoppingApp.RegisterUser("Seller", "seller@example.com")
+	seller, _ := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
 
 	// Act
-	product, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	product, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
@@ -30,7 +31,7 @@ func TestRegisterProduct_SellerNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Act
-	product, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, "nonExistentSeller", 99.99)
+	product, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, "nonExistentSeller", 99.99)
 
 	// Assert
 	assert.Error(t, err)
@@ -42,17 +43,17 @@ func TestRegisterProduct_ProductAlreadyExists(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller user
-	seller, _ := shoppingApp.RegisterUser("Seller", "seller@example.com")
+	seller, _ := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
 
 	// Register the first product
-	p1, err := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	p1, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, p1)
 
 	// Act
-	p2, err := shoppingApp.RegisterProduct("Product 1", "Another description", 20, seller.Id, 89.99)
+	p2, err := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Another description", 20, seller.Id, 89.99)
 
 	// Assert
 	assert.Error(t, err)
@@ -65,13 +66,13 @@ func TestGetProduct_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a seller user
-	seller, _ := shoppingApp.RegisterUser("Seller", "seller@example.com")
+	seller, _ := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
 
 	// Register a product
-	product, _ := shoppingApp.RegisterProduct("Product 1", "Description of product 1", 10, seller.Id, 99.99)
+	product, _ := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 99.99)
 
 	// Act
-	retrievedProduct, err := shoppingApp.GetProduct(product.Id)
+	retrievedProduct, err := shoppingApp.GetProduct(context.Background(), product.Id)
 
 	// Assert
 	assert.NoError(t, err)
@@ -83,10 +84,24 @@ func TestGetProduct_ProductNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Act
-	retrievedProduct, err := shoppingApp.GetProduct("nonExistentProductId")
+	retrievedProduct, err := shoppingApp.GetProduct(context.Background(), "nonExistentProductId")
 
 	// Assert
 	assert.Error(t, err)
 	assert.Nil(t, retrievedProduct)
 	assert.Equal(t, "Product not found", err.Error())
 }
+
+// Test GetProducts returns the products found and leaves out unknown IDs
+func TestGetProducts_SkipsUnknown(t *testing.T) {
+	shoppingApp := createMockEngine()
+	seller, _ := shoppingApp.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	p1, _ := shoppingApp.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10.0)
+	p2, _ := shoppingApp.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 5, seller.Id, 20.0)
+
+	// Act
+	products := shoppingApp.GetProducts(context.Background(), []string{p1.Id, "missing", p2.Id})
+
+	// Assert
+	assert.Equal(t, map[string]*product{p1.Id: p1, p2.Id: p2}, products)
+}
@@ -1,50 +1,115 @@
 package internal
 
 import (
-	"os"
-	"strconv"
+	"context"
+	"sync"
+	"time"
+	"github.com/ecommerce-store/config"
+	"go.uber.org/zap"
 )
 
 type ShoppingEngine interface {
-	RegisterUser(name string, email string) (*user, error)
-	GetUser(userId string) (*user, error)
-	GetUserByUsername(username string) (*user, error)
-	RegisterProduct(name string, description string, quantity int, sellerId string, price float64) (*product, error)
-	GetProduct(productId string) (*product, error)
-	AddToCart(userId string, productId string, quantity int) (map[string]int, error)
-	GetCart(userId string) (map[string]int, error)
-	GetDiscountCoupon(userId string) (string, error)
-	Checkout(userId string, couponCode string) (*order, error)
-	OrderHistory() OrderBook
+	RegisterUser(ctx context.Context, name string, email string) (*user, error)
+	GetUser(ctx context.Context, userId string) (*user, error)
+	GetUserByUsername(ctx context.Context, username string) (*user, error)
+	RegisterProduct(ctx context.Context, name string, description string, quantity int, sellerId string, price float64) (*product, error)
+	GetProduct(ctx context.Context, productId string) (*product, error)
+	GetProducts(ctx context.Context, productIds []string) map[string]*product
+	AddToCart(ctx context.Context, userId string, productId string, quantity int) (map[string]int, error)
+	GetCart(ctx context.Context, userId string) (map[string]int, error)
+	GetDiscountCoupon(ctx context.Context, userId string) (string, error)
+	Checkout(ctx context.Context, userId string, couponCode string) (*order, error)
+	CheckoutWithOptions(ctx context.Context, userId string, options CheckoutOptions) (*order, error)
+	CheckoutWithIdempotencyKey(ctx context.Context, key string, userId string, options CheckoutOptions) (*order, bool, error)
+	GetWallet(ctx context.Context, userId string) (*walletView, error)
+	IssueStoreCredit(ctx context.Context, userId string, amount float64, reference string) (*walletView, error)
+	PurchaseGiftCard(ctx context.Context, userId string, amount float64) (*giftCard, error)
+	RedeemGiftCard(ctx context.Context, userId string, code string) (*walletView, error)
+	SetProductCategory(ctx context.Context, productId string, category string) (*product, error)
+	GetPoints(ctx context.Context, userId string) (*pointsView, error)
+	CancelOrder(ctx context.Context, userId string, orderId string) (*order, error)
+	GetOrder(ctx context.Context, userId string, orderId string) (*order, error)
+	GetOrders(ctx context.Context, userId string) ([]*order, error)
+	WatchOrderStatus(ctx context.Context, userId string, orderId string) (<-chan OrderStatusUpdate, error)
+	GetCouponPolicy(ctx context.Context) CouponPolicy
+	SetCouponPolicy(ctx context.Context, policy CouponPolicy)
+	GetSellerAnalytics(ctx context.Context, sellerId string, lowStockThreshold int) (*sellerAnalytics, error)
+	WatchProduct(ctx context.Context, productId string) error
+	UnwatchProduct(ctx context.Context, productId string)
+	GetWatchedStock(ctx context.Context) map[string]int
+	RecordAudit(ctx context.Context, action string, target string, before interface{}, after interface{})
+	GetAuditLog(ctx context.Context, filter AuditFilter) []auditEntry
+	VerifyAuditLog(ctx context.Context) error
+	GetAuditHead(ctx context.Context) AuditHead
+	SubscribeEvents(ctx context.Context, filter EventFilter, lastEventId uint64) <-chan Event
+	HandlePaymentWebhook(ctx context.Context, payload []byte, signature string) error
+	OrderHistory(ctx context.Context) OrderBook
+	LoadState(ctx context.Context) error
+	SaveState(ctx context.Context) error
+	Close(ctx context.Context)
 }
 
 type shoppingEngine struct {
 	Users             map[string]*user         // Map of users, indexed by userId
 	UserMap           map[string]string        // Map to store username mappings
 	Coupons           map[string]string        // Coupons by userId
-	DiscountInterval  int                      // Discount interval (every N orders)
 	Inventory         *inventory               // Inventory system with products
 	OrderBook         *orderBook               // Order history tracking
+	Idempotency       *idempotencyStore        // Idempotency keys used for checkout
+	Payments          PaymentProvider          // Payment gateway used at checkout
+	WebhookSecret     string                   // Secret used to verify payment webhooks
+	GiftCards         *giftCardBook            // Gift cards sold on the platform
+	Loyalty           *loyaltyProgram          // Rules for earning and redeeming loyalty points
+	Audit             *auditLog                // Tamper-evident record of privileged actions
+	Events            *eventPublisher          // Order and stock changes streamed to users and sellers
+	State             StateStore               // Durable store the state is saved to on shutdown, nil if not configured
+	Tenant            string                   // Tenant served by the engine, empty when the process serves a single store
+	couponPolicy      CouponPolicy             // Decides who is eligible for a discount coupon
+	policyMutex       sync.RWMutex             // Mutex to allow changing the coupon policy at runtime
+	clock             Clock                    // Source of the current time
+	newId             IDGenerator              // Source of user, product, order and ledger entry IDs
+	log               *zap.Logger              // Logger used when the context doesn't carry one
 }
 
-// GetAppInstance creates and returns a singleton instance of the ShoppingEngine
-func GetAppInstance() ShoppingEngine {
-	instance.Do(func() {
-		interval, err := strconv.Atoi(os.Getenv(DiscountIntervalEnv))
-		if err != nil {
-			Logger.Sugar().Debug("Unable to read discount interval from env, using default value!")
-			interval = 5 // Default discount interval if not provided
-		}
-		orderBook := newOrderBook()
-		inventory := newInventory()
-		shoppingApp = &shoppingEngine{
-			Users:            make(map[string]*user),
-			UserMap:          make(map[string]string),
-			OrderBook:        orderBook,
-			Inventory:        inventory,
-			DiscountInterval: interval,
-			Coupons:          make(map[string]string),
-		}
-	})
-	return shoppingApp
-}
\ No newline at end of file
+// NewEngine creates an empty engine configured by cfg, which must have been validated, or by the defaults if cfg is nil.
+// Engines share no state, so several can run in the same process.
+func NewEngine(cfg *config.Config, options ...EngineOption) ShoppingEngine {
+	return newShoppingEngine(cfg, options...)
+}
+
+// newShoppingEngine creates an empty engine, options are applied after the config
+func newShoppingEngine(cfg *config.Config, options ...EngineOption) *shoppingEngine {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	engine := &shoppingEngine{
+		Users:            make(map[string]*user),
+		UserMap:          make(map[string]string),
+		OrderBook:        newOrderBook(),
+		Inventory:        newInventory(),
+		Coupons:          make(map[string]string),
+		Payments:         NewFakeGateway(), // In-process gateway unless WithPaymentProvider sets another
+		WebhookSecret:    cfg.Auth.WebhookSecret,
+		GiftCards:        newGiftCardBook(),
+		Loyalty:          newLoyaltyProgram(cfg.Promotions.Loyalty),
+		Audit:            newAuditLog(),
+		couponPolicy:     &everyNthOrderPolicy{Interval: cfg.Promotions.DiscountInterval}, // Until changed by an admin
+		clock:            time.Now,
+		newId:            generateUUID,
+		log:              Logger,
+	}
+	if cfg.Storage.StateFile != "" {
+		engine.State = NewFileStateStore(cfg.Storage.StateFile)
+	}
+	for _, option := range options {
+		option(engine)
+	}
+	if engine.Tenant != "" {
+		engine.log = engine.log.With(zap.String("tenant", engine.Tenant))
+	}
+
+	// Built once the options have replaced the clock
+	engine.Idempotency = newIdempotencyStore(cfg.Checkout.IdempotencyWindow.Duration, engine.clock)
+	engine.Events = newEventPublisher(engine.clock)
+	return engine
+}
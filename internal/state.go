@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StateStore keeps the engine state durably between restarts
+type StateStore interface {
+	// Load returns the last saved state, or nil if nothing was saved yet
+	Load(ctx context.Context) (*EngineState, error)
+	// Save replaces the saved state
+	Save(ctx context.Context, state *EngineState) error
+}
+
+// EngineState is the durable part of the engine.
+// Idempotency keys and the event history are short-lived and aren't kept, event IDs carry on from LastEventId.
+type EngineState struct {
+	SavedAt           time.Time                 `json:"saved_at"`            // Time the state was saved
+	Tenant            string                    `json:"tenant,omitempty"`    // Tenant of the engine that saved the state
+	Users             []*user                   `json:"users"`               // Users with their carts, wallets and points
+	Coupons           map[string]string         `json:"coupons"`             // Coupons by userId
+	Products          []*product                `json:"products"`            // Every registered product, including removed ones
+	RemovedProducts   []string                  `json:"removed_products"`    // IDs of removed products
+	WatchedProducts   []string                  `json:"watched_products"`    // IDs of products whose stock is exported as a metric
+	Orders            []*order                  `json:"orders"`              // Orders in the order they were placed
+	ItemsSold         int                       `json:"items_sold"`          // Order book counters
+	PurchaseAmount    float64                   `json:"purchase_amount"`
+	TotalDiscount     float64                   `json:"total_discount"`
+	CouponRedemptions int                       `json:"coupon_redemptions"`
+	Counter           int                       `json:"counter"`
+	ProcessedEvents   []string                  `json:"processed_events"`    // IDs of payment webhook events already handled
+	GiftCards         []*giftCard               `json:"gift_cards"`          // Gift cards sold on the platform
+	Audit             []*auditEntry             `json:"audit"`               // Audit log, oldest first
+	AuditHead         *AuditHead                `json:"audit_head,omitempty"` // Last audit entry, shows entries removed from the end of Audit without updating it
+	CouponPolicy      CouponPolicySpec          `json:"coupon_policy"`       // Coupon policy in effect
+	LastEventId       uint64                    `json:"last_event_id"`       // ID of the last published event
+	Payments          *PaymentState             `json:"payments,omitempty"`  // Payments of an in-process provider, so payment IDs aren't reused
+}
+
+// fileStateStore keeps the engine state as JSON in a local file
+type fileStateStore struct {
+	Path   string   // File the state is written to
+}
+
+// NewFileStateStore returns a store keeping the engine state in the file at path
+func NewFileStateStore(path string) StateStore {
+	return &fileStateStore{Path: path}
+}
+
+func (f *fileStateStore) Load(ctx context.Context) (*EngineState, error) {
+	payload, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state EngineState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Save writes to a temporary file first, so a crash while saving leaves the previous state intact
+func (f *fileStateStore) Save(ctx context.Context, state *EngineState) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// snapshot captures the durable state of the engine
+func (s *shoppingEngine) snapshot(ctx context.Context) *EngineState {
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	state := &EngineState{
+		SavedAt:        s.clock().UTC(),
+		Tenant:         s.Tenant,
+		Coupons:        s.Coupons,
+		ItemsSold:      s.OrderBook.ItemsSold,
+		PurchaseAmount: s.OrderBook.PurchaseAmount,
+		TotalDiscount:  s.OrderBook.TotalDiscount,
+		CouponRedemptions: s.OrderBook.CouponRedemptions,
+		Counter:        s.OrderBook.Counter,
+		CouponPolicy:   s.GetCouponPolicy(ctx).Spec(),
+	}
+	for _, user := range s.Users {
+		state.Users = append(state.Users, user)
+	}
+	for _, products := range s.Inventory.ProductsBySeller {
+		for _, product := range products {
+			state.Products = append(state.Products, product)
+			if s.Inventory.Products[product.Id] == nil {
+				state.RemovedProducts = append(state.RemovedProducts, product.Id)
+			}
+		}
+	}
+	for productId := range s.Inventory.Watched {
+		state.WatchedProducts = append(state.WatchedProducts, productId)
+	}
+	for _, order := range s.OrderBook.Orders {
+		state.Orders = append(state.Orders, order)
+	}
+	// Keep the order history of every user in the order it was placed
+	sort.SliceStable(state.Orders, func(i, j int) bool {
+		return state.Orders[i].CreatedAt.Before(state.Orders[j].CreatedAt)
+	})
+	for eventId := range s.OrderBook.ProcessedEvents {
+		state.ProcessedEvents = append(state.ProcessedEvents, eventId)
+	}
+
+	s.GiftCards.Mutex.Lock()
+	for _, card := range s.GiftCards.Cards {
+		state.GiftCards = append(state.GiftCards, card)
+	}
+	s.GiftCards.Mutex.Unlock()
+
+	s.Audit.Mutex.Lock()
+	state.Audit = append([]*auditEntry{}, s.Audit.Entries...)
+	s.Audit.Mutex.Unlock()
+	head := s.Audit.head()
+	state.AuditHead = &head
+
+	s.Events.mutex.Lock()
+	state.LastEventId = s.Events.lastId
+	s.Events.mutex.Unlock()
+
+	if holder, ok := s.Payments.(paymentStateHolder); ok {
+		state.Payments = holder.paymentState()
+	}
+	return state
+}
+
+// restore replaces the contents of the engine with the saved state and rebuilds the indexes, before the engine is used
+func (s *shoppingEngine) restore(state *EngineState) error {
+	policy, err := NewCouponPolicy(state.CouponPolicy)
+	if err != nil {
+		return err
+	}
+	s.couponPolicy = policy
+
+	for _, user := range state.Users {
+		// Ledgers keep stamping entries with this engine's clock and IDs, users saved before they existed start empty
+		if user.Wallet == nil {
+			user.Wallet = newWallet(s.clock, s.newId)
+		}
+		if user.Points == nil {
+			user.Points = newPointsAccount(s.newId)
+		}
+		user.Wallet.clock, user.Wallet.newId = s.clock, s.newId
+		user.Points.newId = s.newId
+		s.Users[user.Id] = user
+		s.UserMap[user.Email] = user.Id
+	}
+	for userId, coupon := range state.Coupons {
+		s.Coupons[userId] = coupon
+	}
+
+	removed := make(map[string]bool, len(state.RemovedProducts))
+	for _, productId := range state.RemovedProducts {
+		removed[productId] = true
+	}
+	for _, product := range state.Products {
+		s.Inventory.ProductsBySeller[product.SellerId] = append(s.Inventory.ProductsBySeller[product.SellerId], product)
+		if removed[product.Id] {
+			s.Inventory.Products[product.Id] = nil
+		} else {
+			s.Inventory.Products[product.Id] = product
+		}
+	}
+	for _, productId := range state.WatchedProducts {
+		s.Inventory.Watched[productId] = true
+	}
+
+	for _, order := range state.Orders {
+		s.OrderBook.Orders[order.Id] = order
+		s.OrderBook.OrdersByUserId[order.UserId] = append(s.OrderBook.OrdersByUserId[order.UserId], order)
+		if order.PaymentId != "" {
+			s.OrderBook.OrdersByPaymentId[order.PaymentId] = order
+		}
+	}
+	s.OrderBook.ItemsSold = state.ItemsSold
+	s.OrderBook.PurchaseAmount = state.PurchaseAmount
+	s.OrderBook.TotalDiscount = state.TotalDiscount
+	s.OrderBook.CouponRedemptions = state.CouponRedemptions
+	s.OrderBook.Counter = state.Counter
+	for _, eventId := range state.ProcessedEvents {
+		s.OrderBook.ProcessedEvents[eventId] = true
+	}
+
+	for _, card := range state.GiftCards {
+		s.GiftCards.Cards[card.Code] = card
+	}
+	s.Audit.Entries = state.Audit
+	if state.AuditHead != nil {
+		s.Audit.Anchor = *state.AuditHead
+	}
+	s.Events.lastId = state.LastEventId
+	if holder, ok := s.Payments.(paymentStateHolder); ok && state.Payments != nil {
+		holder.restorePayments(state.Payments)
+	}
+	return nil
+}
+
+// LoadState restores the engine from its state store, if one is configured and holds a saved state
+func (s *shoppingEngine) LoadState(ctx context.Context) error {
+	if s.State == nil {
+		return nil
+	}
+	state, err := s.State.Load(ctx)
+	if err != nil || state == nil {
+		return err
+	}
+	// Refuse to serve another tenant's users and orders
+	if state.Tenant != s.Tenant {
+		return fmt.Errorf("state was saved by tenant %q, not %q", state.Tenant, s.Tenant)
+	}
+	if err := s.restore(state); err != nil {
+		return err
+	}
+	s.logger(ctx).Sugar().Infof("Engine state saved at %s restored, audit log anchored at entry %d (%s)", state.SavedAt.Format(time.RFC3339), s.Audit.Anchor.Sequence, s.Audit.Anchor.Hash)
+	return nil
+}
+
+// SaveState writes the engine state to its state store, if one is configured
+func (s *shoppingEngine) SaveState(ctx context.Context) error {
+	if s.State == nil {
+		return nil
+	}
+	state := s.snapshot(ctx)
+	if err := s.State.Save(ctx, state); err != nil {
+		return err
+	}
+	// The logs keep the audit head outside the state file, so a truncated log can be told from an old one
+	s.logger(ctx).Sugar().Infof("Engine state saved, audit log head is entry %d (%s)", state.AuditHead.Sequence, state.AuditHead.Hash)
+	return nil
+}
+
+// Close ends every event subscription and order status watch so streaming requests can finish, later ones end right away
+func (s *shoppingEngine) Close(ctx context.Context) {
+	s.Events.close()
+
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+	s.OrderBook.Closed = true
+	for orderId, watchers := range s.OrderBook.Watchers {
+		for len(watchers) > 0 {
+			s.removeOrderWatcher(orderId, watchers[0])
+			watchers = s.OrderBook.Watchers[orderId]
+		}
+	}
+	s.logger(ctx).Sugar().Debug("Event subscriptions and order watches closed")
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the state saved by one engine is restored by another
+func TestSaveState_Restore(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	shoppingApp := createMockEngine(WithStateStore(store))
+	order, p1 := createPaidOrder(t, shoppingApp)
+	assert.NoError(t, shoppingApp.RemoveProduct(context.Background(), p1.Id))
+	card, err := shoppingApp.PurchaseGiftCard(context.Background(), order.UserId, 25.0)
+	assert.NoError(t, err)
+	shoppingApp.SetCouponPolicy(context.Background(), &firstOrderPolicy{})
+	shoppingApp.Events.publish(EventStockChanged, "", nil, nil)
+
+	// Act
+	assert.NoError(t, shoppingApp.SaveState(context.Background()))
+	restored := createMockEngine(WithStateStore(store))
+	assert.NoError(t, restored.LoadState(context.Background()))
+
+	// Assert
+	user, err := restored.GetUserByUsername(context.Background(), "aditya@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, order.UserId, user.Id)
+	restoredOrder, err := restored.GetOrder(context.Background(), user.Id, order.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, order.AmountToPay, restoredOrder.AmountToPay)
+	assert.Equal(t, order.Items, restoredOrder.Items)
+	assert.Equal(t, restoredOrder, restored.OrderBook.OrdersByPaymentId[order.PaymentId])
+	assert.Equal(t, user.Points.Balance(order.CreatedAt), shoppingApp.Users[user.Id].Points.Balance(order.CreatedAt))
+
+	_, err = restored.GetProduct(context.Background(), p1.Id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Len(t, restored.Inventory.ProductsBySeller[p1.SellerId], 1)
+	assert.Equal(t, shoppingApp.GiftCards.Cards[card.Code], restored.GiftCards.Cards[card.Code])
+	assert.Equal(t, CouponPolicyFirstOrder, restored.GetCouponPolicy(context.Background()).Spec().Type)
+	assert.NoError(t, restored.VerifyAuditLog(context.Background()))
+	assert.Len(t, restored.Audit.Entries, len(shoppingApp.Audit.Entries))
+	assert.Equal(t, shoppingApp.Events.lastId, restored.Events.lastId)
+
+	// Act
+	seller, err := restored.GetUserByUsername(context.Background(), "seller@example.com")
+	assert.NoError(t, err)
+	p2, err := restored.RegisterProduct(context.Background(), "Product 2", "Description of product 2", 10, seller.Id, 20.0)
+	assert.NoError(t, err)
+	_, err = restored.AddToCart(context.Background(), user.Id, p2.Id, 1)
+	assert.NoError(t, err)
+	newOrder, err := restored.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	cancelled, cancelErr := restored.CancelOrder(context.Background(), user.Id, order.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, order.PaymentId, newOrder.PaymentId)
+	assert.NotEqual(t, card.PaymentId, newOrder.PaymentId)
+	assert.Equal(t, order.Id, restored.OrderBook.OrdersByPaymentId[order.PaymentId].Id)
+	assert.NoError(t, cancelErr)
+	assert.Equal(t, OrderStatusCancelled, cancelled.Status)
+	payment, _ := restored.Payments.(*FakeGateway).Payment(order.PaymentId)
+	assert.Equal(t, order.AmountToPay, payment.Refunded)
+}
+
+// Test loading without a saved state leaves the engine empty
+func TestLoadState_NothingSaved(t *testing.T) {
+	shoppingApp := createMockEngine(WithStateStore(NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))))
+
+	// Act
+	err := shoppingApp.LoadState(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, shoppingApp.Users)
+}
+
+// Test closing the engine ends open and new event streams
+func TestClose_EndsStreams(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+	events := shoppingApp.SubscribeEvents(context.Background(), EventFilter{}, shoppingApp.Events.lastId)
+	updates, err := shoppingApp.WatchOrderStatus(context.Background(), order.UserId, order.Id)
+	assert.NoError(t, err)
+
+	// Act
+	shoppingApp.Close(context.Background())
+
+	// Assert
+	_, open := <-events
+	assert.False(t, open)
+	assert.Equal(t, OrderStatusPaid, (<-updates).Status)
+	_, open = <-updates
+	assert.False(t, open)
+	_, open = <-shoppingApp.SubscribeEvents(context.Background(), EventFilter{}, shoppingApp.Events.lastId)
+	assert.False(t, open)
+	later, err := shoppingApp.WatchOrderStatus(context.Background(), order.UserId, order.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusPaid, (<-later).Status)
+	_, open = <-later
+	assert.False(t, open)
+}
+
+// Test an engine refuses the state saved by another tenant
+func TestLoadState_OtherTenant(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	acme := createMockEngine(WithStateStore(store), WithTenant("acme"))
+	createPaidOrder(t, acme)
+	assert.NoError(t, acme.SaveState(context.Background()))
+	globex := createMockEngine(WithStateStore(store), WithTenant("globex"))
+
+	// Act
+	err := globex.LoadState(context.Background())
+
+	// Assert
+	assert.ErrorContains(t, err, `state was saved by tenant "acme", not "globex"`)
+	assert.Empty(t, globex.Users)
+}
+
+// Test audit entries removed from the end of a saved state are detected against the saved head
+func TestLoadState_TruncatedAuditLog(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	shoppingApp := createMockEngine(WithStateStore(store))
+	for _, target := range []string{"a", "b", "c"} {
+		shoppingApp.RecordAudit(context.Background(), AuditProductWatched, target, nil, nil)
+	}
+	assert.NoError(t, shoppingApp.SaveState(context.Background()))
+	state, err := store.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, AuditHead{Sequence: 3, Hash: shoppingApp.Audit.Entries[2].Hash}, *state.AuditHead)
+	state.Audit = state.Audit[:2]
+	assert.NoError(t, store.Save(context.Background(), state))
+	restored := createMockEngine(WithStateStore(store))
+
+	// Act
+	assert.NoError(t, restored.LoadState(context.Background()))
+	err = restored.VerifyAuditLog(context.Background())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrAuditChainBroken)
+	assert.ErrorContains(t, err, "ends at entry 2 before the anchored entry 3")
+}
+
+// Test users saved without a wallet or points account are restored with empty ones
+func TestLoadState_MissingLedgers(t *testing.T) {
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	shoppingApp := createMockEngine(WithStateStore(store))
+	order, _ := createPaidOrder(t, shoppingApp)
+	card, err := shoppingApp.PurchaseGiftCard(context.Background(), order.UserId, 25.0)
+	assert.NoError(t, err)
+	assert.NoError(t, shoppingApp.SaveState(context.Background()))
+	state, err := store.Load(context.Background())
+	assert.NoError(t, err)
+	for _, user := range state.Users {
+		user.Wallet, user.Points = nil, nil
+	}
+	assert.NoError(t, store.Save(context.Background(), state))
+	restored := createMockEngine(WithStateStore(store))
+
+	// Act
+	assert.NoError(t, restored.LoadState(context.Background()))
+	points, pointsErr := restored.GetPoints(context.Background(), order.UserId)
+	wallet, walletErr := restored.RedeemGiftCard(context.Background(), order.UserId, card.Code)
+
+	// Assert
+	assert.NoError(t, pointsErr)
+	assert.Equal(t, 0, points.Balance)
+	assert.NoError(t, walletErr)
+	assert.Equal(t, 25.0, wallet.Balance)
+	assert.Len(t, wallet.Ledger, 1)
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// createTestTracer installs a tracer provider that keeps finished spans in memory
+func createTestTracer(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return exporter
+}
+
+// spansByName indexes the finished spans by name
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+// Test checkout creates spans for pricing, stock, payment and persistence under the checkout span
+func TestCheckout_Spans(t *testing.T) {
+	exporter := createTestTracer(t)
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	// Act
+	_, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.NoError(t, err)
+	spans := spansByName(exporter)
+	checkout := spans["checkout"]
+	place := spans["order.place"]
+	assert.False(t, checkout.Parent.IsValid())
+	assert.Equal(t, checkout.SpanContext.SpanID(), spans["cart.price"].Parent.SpanID())
+	assert.Equal(t, checkout.SpanContext.SpanID(), place.Parent.SpanID())
+	for _, name := range []string{"stock.deduct", "payment.authorize", "order.persist", "payment.capture"} {
+		assert.Equal(t, place.SpanContext.SpanID(), spans[name].Parent.SpanID(), name)
+		assert.Equal(t, checkout.SpanContext.TraceID(), spans[name].SpanContext.TraceID(), name)
+	}
+	assert.Equal(t, codes.Unset, checkout.Status.Code)
+}
+
+// Test a failed checkout marks the failing spans as errors
+func TestCheckout_SpansOutOfStock(t *testing.T) {
+	exporter := createTestTracer(t)
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+	p1.Quantity = 1
+
+	// Act
+	_, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+
+	// Assert
+	assert.Error(t, err)
+	spans := spansByName(exporter)
+	assert.Equal(t, codes.Error, spans["stock.deduct"].Status.Code)
+	assert.Equal(t, codes.Error, spans["order.place"].Status.Code)
+	assert.Equal(t, codes.Error, spans["checkout"].Status.Code)
+	assert.NotContains(t, spans, "payment.charge")
+	assert.NotContains(t, spans, "order.persist")
+}
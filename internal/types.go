@@ -0,0 +1,9 @@
+package internal
+
+// Exported names of the engine's types, for adapters outside the package that need to convert them
+type (
+	User     = user
+	Product  = product
+	Order    = order
+	LineItem = lineItem
+)
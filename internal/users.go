@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"fmt"
+	"context"
 )
 
 // user represents a customer or user in the system
@@ -10,55 +10,59 @@ type user struct {
 	Name   		string            	// Name of the user
 	Email       string            	// Email address of the user
 	Cart        map[string]int   	// Map of product IDs and quantities in the user's cart
+	Wallet      *wallet             // Store credit balance and ledger
+	Points      *pointsAccount      // Loyalty points ledger
 }
 
 // newUser creates and returns a new user instance
-func newUser(id string, name string, email string) *user {
+func newUser(id string, name string, email string, clock Clock, newId IDGenerator) *user {
 	return &user{
 		Id:       id,        
 		Name:     name,      
 		Email:    email,
 		Cart:     make(map[string]int),
+		Wallet:   newWallet(clock, newId),
+		Points:   newPointsAccount(newId),
 	}
 }
 
 // RegisterUser registers a new user in the system, using email as a unique identifier
-func (s *shoppingEngine) RegisterUser(name string, email string) (*user, error) {
+func (s *shoppingEngine) RegisterUser(ctx context.Context, name string, email string) (*user, error) {
 	// Check if the email is already registered
 	if s.UserMap[email] != "" {
-		return nil, fmt.Errorf("Email already exists") // Error if email is already registered
+		return nil, newError(ErrConflict, "Email already exists") // Error if email is already registered
 	}
 	
 	// Generate a unique ID and create a new user
-	id := generateUUID()
-	user := newUser(id, name, email)
+	id := s.newId()
+	user := newUser(id, name, email, s.clock, s.newId)
 
 	// Store the user in the system's user map and map email to user ID
 	s.Users[id] = user
 	s.UserMap[email] = id
 
-	Logger.Sugar().Infof("User with username %s registered successfully", email)
+	s.logger(ctx).Sugar().Infof("User with username %s registered successfully", email)
 	return user, nil
 }
 
 // GetUser retrieves a user by their unique user ID
-func (s *shoppingEngine) GetUser(userId string) (*user, error) {
+func (s *shoppingEngine) GetUser(ctx context.Context, userId string) (*user, error) {
 	// Check if the user exists in the system
 	if s.Users[userId] == nil {
-		return nil, fmt.Errorf("User not found!") // Error if the user is not found
+		return nil, newError(ErrNotFound, "User not found!") // Error if the user is not found
 	}
 	return s.Users[userId], nil
 }
 
 // GetUserByUsername retrieves a user by their email/username
-func (s *shoppingEngine) GetUserByUsername(username string) (*user, error) {
+func (s *shoppingEngine) GetUserByUsername(ctx context.Context, username string) (*user, error) {
 	// Check if the username exists in the user map
 	if s.UserMap[username] == "" {
-		return nil, fmt.Errorf("Username %s doesn't exist", username) // Error if the username does not exist
+		return nil, newError(ErrNotFound, "Username %s doesn't exist", username) // Error if the username does not exist
 	}
 	
 	// Retrieve the user using the user ID from the email
-	user, err := s.GetUser(s.UserMap[username])
+	user, err := s.GetUser(ctx, s.UserMap[username])
 	if err != nil {
 		// If error occurs, remove the invalid username from the map and return the error
 		delete(s.UserMap, username)
@@ -67,15 +71,16 @@ func (s *shoppingEngine) GetUserByUsername(username string) (*user, error) {
 	return user, nil
 }
 
-func (s *shoppingEngine) RemoveUser(userId string) error {
+func (s *shoppingEngine) RemoveUser(ctx context.Context, userId string) error {
 	// Check if the user exists in the system
 	if s.Users[userId] == nil {
-		return fmt.Errorf("User not found") // Error if the user is not found
+		return newError(ErrNotFound, "User not found") // Error if the user is not found
 	}
 	username := s.Users[userId].Email
+	s.audit(ctx, AuditUserRemoved, userId, map[string]string{"name": s.Users[userId].Name, "email": username}, nil)
 	delete(s.Users, userId)
 	delete(s.UserMap, username)
 
-	Logger.Sugar().Infof("User %s removed successfully", userId)
+	s.logger(ctx).Sugar().Infof("User %s removed successfully", userId)
 	return nil
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"testing"
 	"github.com/stretchr/testify/assert"
 )
@@ -10,7 +11,7 @@ func TestRegisterUser_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Act
-	user, err := shoppingApp.RegisterUser("hailey", "hailey@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "hailey", "hailey@example.com")
 
 	// Assert
 	assert.NoError(t, err)
@@ -26,14 +27,14 @@ func TestRegisterUser_EmailAlreadyExists(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user
-	user, err := shoppingApp.RegisterUser("aaron", "aaron@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "aaron", "aaron@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
 	// Act
-	_, err = shoppingApp.RegisterUser("alex", "aaron@example.com")
+	_, err = shoppingApp.RegisterUser(context.Background(), "alex", "aaron@example.com")
 
 	// Assert
 	assert.Error(t, err)
@@ -45,14 +46,14 @@ func TestGetUser_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user and get the user ID
-	user, err := shoppingApp.RegisterUser("Shahrukh", "shah@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Shahrukh", "shah@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUser(user.Id)
+	retrievedUser, err := shoppingApp.GetUser(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
@@ -63,13 +64,13 @@ func TestRemoveUser_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user
-	user, err := shoppingApp.RegisterUser("Prem", "prem@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Prem", "prem@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	
-	err = shoppingApp.RemoveUser(user.Id)
+	err = shoppingApp.RemoveUser(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
@@ -78,7 +79,7 @@ func TestRemoveUser_Success(t *testing.T) {
 func TestRemoveUser_UserNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 	
-	err := shoppingApp.RemoveUser("nonExistentUserId")
+	err := shoppingApp.RemoveUser(context.Background(), "nonExistentUserId")
 
 	// Assert
 	assert.Error(t, err)
@@ -89,7 +90,7 @@ func TestGetUser_UserNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUser("nonExistentUserId")
+	retrievedUser, err := shoppingApp.GetUser(context.Background(), "nonExistentUserId")
 
 	// Assert
 	assert.Error(t, err)
@@ -102,19 +103,19 @@ func TestGetUser_UserRemoved(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user
-	user, err := shoppingApp.RegisterUser("Abdul", "abdul@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "Abdul", "abdul@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	
-	err = shoppingApp.RemoveUser(user.Id)
+	err = shoppingApp.RemoveUser(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUser(user.Id)
+	retrievedUser, err := shoppingApp.GetUser(context.Background(), user.Id)
 
 	// Assert
 	assert.Error(t, err)
@@ -127,14 +128,14 @@ func TestGetUserByUsername_Success(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user
-	user, err := shoppingApp.RegisterUser("smith", "smith@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "smith", "smith@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUserByUsername("smith@example.com")
+	retrievedUser, err := shoppingApp.GetUserByUsername(context.Background(), "smith@example.com")
 
 	// Assert
 	assert.NoError(t, err)
@@ -146,7 +147,7 @@ func TestGetUserByUsername_UserNotFound(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUserByUsername("nonexistent.email@example.com")
+	retrievedUser, err := shoppingApp.GetUserByUsername(context.Background(), "nonexistent.email@example.com")
 
 	// Assert
 	assert.Error(t, err)
@@ -158,19 +159,19 @@ func TestGetUserByUsername_UserRemoved(t *testing.T) {
 	shoppingApp := createMockEngine()
 
 	// Register a user
-	user, err := shoppingApp.RegisterUser("John cena", "john@example.com")
+	user, err := shoppingApp.RegisterUser(context.Background(), "John cena", "john@example.com")
 
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	
-	err = shoppingApp.RemoveUser(user.Id)
+	err = shoppingApp.RemoveUser(context.Background(), user.Id)
 
 	// Assert
 	assert.NoError(t, err)
 
 	// Act
-	retrievedUser, err := shoppingApp.GetUserByUsername("john@example.com")
+	retrievedUser, err := shoppingApp.GetUserByUsername(context.Background(), "john@example.com")
 
 	// Assert
 	assert.Error(t, err)
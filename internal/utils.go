@@ -1,7 +1,8 @@
 package internal
 
 import (
-	"sync"
+	cryptorand "crypto/rand"
+	"math/big"
 	"math/rand"
     "time"
     "strings"
@@ -11,14 +12,10 @@ import (
 )
 
 var (
-	shoppingApp 	*shoppingEngine
-	instance        sync.Once
-    Logger = utilities.Logger.Session("dev", "eCommerce-store")
+    // Replaced by the logger main configures, which is the only one writing to files
+    Logger = utilities.Logger.Session(utilities.BootstrapLoggerConfig("eCommerce-store"))
 )
 
-// nTH order (value of n)
-const DiscountIntervalEnv = "DISCOUNT_INTERVAL"
-
 // GenerateUUID generates a new UUID
 func generateUUID() string {
     // Create a new UUID
@@ -44,4 +41,20 @@ func generateCouponCode(length int) string {
     }
 
     return builder.String()
-}
\ No newline at end of file
+}
+
+// generateGiftCardCode generates a gift card code from a cryptographically secure source, as the code alone spends the card
+func generateGiftCardCode(length int) (string, error) {
+    const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+    var builder strings.Builder
+    for i := 0; i < length; i++ {
+        index, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(len(chars))))
+        if err != nil {
+            return "", err
+        }
+        builder.WriteByte(chars[index.Int64()])
+    }
+
+    return builder.String(), nil
+}
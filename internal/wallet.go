@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// walletEntryType tells whether a ledger entry added or removed funds
+type walletEntryType string
+
+const (
+	WalletCredit walletEntryType = "credit" // Funds added to the wallet
+	WalletDebit  walletEntryType = "debit"  // Funds spent from the wallet
+)
+
+// Reasons recorded on wallet ledger entries
+const (
+	WalletReasonGiftCard    = "gift_card"    // Gift card redeemed into the wallet
+	WalletReasonStoreCredit = "store_credit" // Store credit issued by an admin
+	WalletReasonOrder       = "order"        // Wallet used to pay for an order
+	WalletReasonOrderRefund = "order_refund" // Wallet payment returned for a failed or refunded order
+)
+
+// walletEntry is a single credit or debit in a user's wallet ledger
+type walletEntry struct {
+	Id          string            `json:"id"`          // Unique entry ID
+	Type        walletEntryType   `json:"type"`        // Credit or debit
+	Amount      float64           `json:"amount"`      // Amount moved by the entry
+	Reason      string            `json:"reason"`      // Why the funds moved
+	Reference   string            `json:"reference"`   // Gift card code or order ID the entry relates to
+	Balance     float64           `json:"balance"`     // Wallet balance after the entry
+	CreatedAt   time.Time         `json:"created_at"`  // Time the entry was recorded
+}
+
+// wallet holds a user's store credit balance and its ledger
+type wallet struct {
+	Balance     float64          `json:"balance"`     // Current balance
+	Ledger      []*walletEntry   `json:"ledger"`      // All credits and debits, oldest first
+	mutex       sync.Mutex       // Mutex to prevent race conditions on the balance
+	clock       Clock            // Clock the entries are timestamped with
+	newId       IDGenerator      // Generator of entry IDs
+}
+
+// walletView is a copy of a wallet's balance and ledger, safe to read while the wallet keeps changing
+type walletView struct {
+	Balance     float64          `json:"balance"`     // Balance when the copy was taken
+	Ledger      []*walletEntry   `json:"ledger"`      // Credits and debits up to then, oldest first
+}
+
+// newWallet creates and returns an empty wallet
+func newWallet(clock Clock, newId IDGenerator) *wallet {
+	return &wallet{clock: clock, newId: newId}
+}
+
+// Credit adds funds to the wallet and records the entry
+func (w *wallet) Credit(amount float64, reason string, reference string) (*walletEntry, error) {
+	if amount <= 0 {
+		return nil, newError(ErrValidation, "Credit amount must be positive")
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.Balance += amount
+	return w.record(WalletCredit, amount, reason, reference), nil
+}
+
+// Debit removes funds from the wallet, returns an error if the balance is insufficient
+func (w *wallet) Debit(amount float64, reason string, reference string) (*walletEntry, error) {
+	if amount <= 0 {
+		return nil, newError(ErrValidation, "Debit amount must be positive")
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.Balance < amount {
+		return nil, newError(ErrValidation, "Insufficient wallet balance")
+	}
+	w.Balance -= amount
+	return w.record(WalletDebit, amount, reason, reference), nil
+}
+
+// GetBalance returns the current wallet balance
+func (w *wallet) GetBalance() float64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.Balance
+}
+
+// view returns a copy of the balance and ledger, entries are never changed once recorded so they are shared
+func (w *wallet) view() *walletView {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return &walletView{Balance: w.Balance, Ledger: append([]*walletEntry{}, w.Ledger...)}
+}
+
+// MarshalJSON encodes a copy taken under the mutex, so encoding doesn't race with credits and debits
+func (w *wallet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.view())
+}
+
+// record appends an entry to the ledger, the caller must hold the mutex
+func (w *wallet) record(entryType walletEntryType, amount float64, reason string, reference string) *walletEntry {
+	entry := &walletEntry{
+		Id:        w.newId(),
+		Type:      entryType,
+		Amount:    amount,
+		Reason:    reason,
+		Reference: reference,
+		Balance:   w.Balance,
+		CreatedAt: w.clock().UTC(),
+	}
+	w.Ledger = append(w.Ledger, entry)
+	return entry
+}
+
+// GetWallet returns the wallet of the user
+func (s *shoppingEngine) GetWallet(ctx context.Context, userId string) (*walletView, error) {
+	user, err := s.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return user.Wallet.view(), nil
+}
+
+// IssueStoreCredit credits the user's wallet, e.g. as compensation for a refund
+func (s *shoppingEngine) IssueStoreCredit(ctx context.Context, userId string, amount float64, reference string) (*walletView, error) {
+	user, err := s.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	before := user.Wallet.GetBalance()
+	if _, err := user.Wallet.Credit(amount, WalletReasonStoreCredit, reference); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, AuditStoreCreditIssued, userId,
+		map[string]interface{}{"balance": before},
+		map[string]interface{}{"balance": user.Wallet.GetBalance(), "amount": amount, "reference": reference})
+
+	s.logger(ctx).Sugar().Infof("Store credit of %.2f issued to user: %s", amount, userId)
+	return user.Wallet.view(), nil
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a purchased gift card can be redeemed once
+func TestRedeemGiftCard_Success(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+
+	buyer, err := shoppingApp.RegisterUser(context.Background(), "Maya", "maya@example.com")
+	assert.NoError(t, err)
+	friend, err := shoppingApp.RegisterUser(context.Background(), "Aditya", "aditya@example.com")
+	assert.NoError(t, err)
+
+	// Act
+	card, err := shoppingApp.PurchaseGiftCard(context.Background(), buyer.Id, 50)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, card.Code)
+	payment, _ := gateway.Payment(card.PaymentId)
+	assert.Equal(t, 50.0, payment.Captured)
+
+	// Act
+	wallet, err := shoppingApp.RedeemGiftCard(context.Background(), friend.Id, card.Code)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, wallet.Balance)
+	assert.Len(t, wallet.Ledger, 1)
+	assert.Equal(t, WalletCredit, wallet.Ledger[0].Type)
+	assert.Equal(t, card.Code, wallet.Ledger[0].Reference)
+
+	// Act
+	_, err = shoppingApp.RedeemGiftCard(context.Background(), friend.Id, card.Code)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, 50.0, wallet.Balance)
+}
+
+// Test gift card codes are unique and don't depend on the time of purchase
+func TestPurchaseGiftCard_UnguessableCodes(t *testing.T) {
+	purchasedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	shoppingApp := createMockEngine(WithClock(func() time.Time { return purchasedAt }))
+	buyer, err := shoppingApp.RegisterUser(context.Background(), "Maya", "maya@example.com")
+	assert.NoError(t, err)
+
+	// Act
+	codes := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		card, err := shoppingApp.PurchaseGiftCard(context.Background(), buyer.Id, 10)
+		assert.NoError(t, err)
+		codes[card.Code] = true
+	}
+
+	// Assert
+	assert.Len(t, codes, 100)
+	for code := range codes {
+		assert.Regexp(t, `^[A-Z0-9]{16}$`, code)
+	}
+}
+
+// Test a declined payment doesn't issue a gift card
+func TestPurchaseGiftCard_Declined(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	gateway.DeclineNext(PaymentAuthorize, "card expired")
+
+	buyer, err := shoppingApp.RegisterUser(context.Background(), "Maya", "maya@example.com")
+	assert.NoError(t, err)
+
+	// Act
+	card, err := shoppingApp.PurchaseGiftCard(context.Background(), buyer.Id, 50)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.Nil(t, card)
+	assert.Empty(t, shoppingApp.GiftCards.Cards)
+}
+
+// Test the returned wallet is a copy that doesn't change with later credits
+func TestGetWallet_Snapshot(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, err := shoppingApp.RegisterUser(context.Background(), "Maya", "maya@example.com")
+	assert.NoError(t, err)
+	_, err = shoppingApp.IssueStoreCredit(context.Background(), user.Id, 10, "goodwill")
+	assert.NoError(t, err)
+
+	// Act
+	wallet, err := shoppingApp.GetWallet(context.Background(), user.Id)
+	assert.NoError(t, err)
+	_, err = shoppingApp.IssueStoreCredit(context.Background(), user.Id, 5, "goodwill")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 10.0, wallet.Balance)
+	assert.Len(t, wallet.Ledger, 1)
+	assert.Equal(t, 15.0, user.Wallet.GetBalance())
+}
+
+// Test an order paid partly from the wallet
+func TestCheckoutWithOptions_PartialWallet(t *testing.T) {
+	gateway := NewFakeGateway()
+	shoppingApp := createMockEngine(WithPaymentProvider(gateway))
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	_, err := shoppingApp.IssueStoreCredit(context.Background(), user.Id, 50, "refund")
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{WalletAmount: 50})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, order.PaidFromWallet)
+	assert.InDelta(t, order.AmountToPay-50, order.PaidByProvider, 1e-9)
+	payment, _ := gateway.Payment(order.PaymentId)
+	assert.InDelta(t, order.PaidByProvider, payment.Captured, 1e-9)
+	assert.Equal(t, 0.0, user.Wallet.Balance)
+	assert.Equal(t, WalletDebit, user.Wallet.Ledger[1].Type)
+	assert.Equal(t, order.Id, user.Wallet.Ledger[1].Reference)
+}
+
+// Test an order paid entirely from the wallet doesn't touch the payment provider
+func TestCheckoutWithOptions_FullWallet(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, _ := createUserWithCart(t, shoppingApp)
+
+	_, err := shoppingApp.IssueStoreCredit(context.Background(), user.Id, 500, "refund")
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{WalletAmount: 500})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, order.AmountToPay, order.PaidFromWallet) // Capped at the amount to pay
+	assert.Equal(t, 0.0, order.PaidByProvider)
+	assert.Empty(t, order.PaymentId)
+	assert.InDelta(t, 500-order.AmountToPay, user.Wallet.Balance, 1e-9)
+}
+
+// Test checkout fails when the wallet can't cover the requested amount
+func TestCheckoutWithOptions_InsufficientWallet(t *testing.T) {
+	shoppingApp := createMockEngine()
+	user, p1 := createUserWithCart(t, shoppingApp)
+
+	_, err := shoppingApp.IssueStoreCredit(context.Background(), user.Id, 10, "refund")
+	assert.NoError(t, err)
+
+	// Act
+	order, err := shoppingApp.CheckoutWithOptions(context.Background(), user.Id, CheckoutOptions{WalletAmount: 50})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, order)
+	assert.Equal(t, 10, p1.Quantity)
+	assert.Equal(t, 10.0, user.Wallet.Balance)
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidWebhookSignature is returned when the payload signature doesn't match
+	ErrInvalidWebhookSignature = errors.New("Invalid webhook signature")
+	// ErrInvalidWebhookEvent is returned when the payload can't be understood
+	ErrInvalidWebhookEvent = newError(ErrValidation, "Invalid webhook event")
+	// ErrPaymentNotFound is returned when no order is known for the payment yet
+	ErrPaymentNotFound = newError(ErrNotFound, "Payment not found")
+)
+
+// Payment event types sent by the payment provider
+const (
+	PaymentEventSucceeded = "payment.succeeded"
+	PaymentEventFailed    = "payment.failed"
+	PaymentEventRefunded  = "payment.refunded"
+	PaymentEventDisputed  = "payment.disputed"
+)
+
+// PaymentEvent is a notification sent by the payment provider
+type PaymentEvent struct {
+	Id          string      `json:"id"`           // Unique event ID used for de-duplication
+	Type        string      `json:"type"`         // One of the PaymentEvent* types
+	PaymentId   string      `json:"payment_id"`   // Payment the event refers to
+	CreatedAt   time.Time   `json:"created_at"`   // Time the event happened at the provider
+}
+
+// eventStatuses maps event types onto order statuses
+var eventStatuses = map[string]orderStatus{
+	PaymentEventSucceeded: OrderStatusPaid,
+	PaymentEventFailed:    OrderStatusFailed,
+	PaymentEventRefunded:  OrderStatusRefunded,
+	PaymentEventDisputed:  OrderStatusDisputed,
+}
+
+// allowedTransitions lists the statuses an order may move to from each status
+var allowedTransitions = map[orderStatus][]orderStatus{
+	OrderStatusAuthorized: {OrderStatusPaid, OrderStatusFailed, OrderStatusRefunded, OrderStatusDisputed},
+	OrderStatusPaid:       {OrderStatusRefunded, OrderStatusDisputed},
+	OrderStatusDisputed:   {OrderStatusPaid, OrderStatusRefunded},
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 signature of the payload
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks the signature in constant time
+func verifyWebhookSignature(secret string, payload []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	expected := SignWebhookPayload(secret, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// canTransition reports whether an order may move from one status to another
+func canTransition(from orderStatus, to orderStatus) bool {
+	for _, status := range allowedTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor recorded for changes made by payment events
+const paymentProviderActor = "payment_provider"
+
+// HandlePaymentWebhook verifies a payment event and applies it to the matching order
+func (s *shoppingEngine) HandlePaymentWebhook(ctx context.Context, payload []byte, signature string) error {
+	if !verifyWebhookSignature(s.WebhookSecret, payload, signature) {
+		return ErrInvalidWebhookSignature
+	}
+
+	var event PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookEvent, err)
+	}
+	status, ok := eventStatuses[event.Type]
+	if event.Id == "" || event.PaymentId == "" || !ok {
+		return fmt.Errorf("%w: missing id, payment_id or unknown type", ErrInvalidWebhookEvent)
+	}
+	// Events are ordered by the provider's timestamp, so one without it can't be placed
+	if event.CreatedAt.IsZero() {
+		return fmt.Errorf("%w: missing created_at", ErrInvalidWebhookEvent)
+	}
+
+	// Lock to keep order updates consistent with checkout
+	s.OrderBook.OrderMutex.Lock()
+	defer s.OrderBook.OrderMutex.Unlock()
+
+	// Providers deliver events at least once, so ignore events we have already seen
+	if s.OrderBook.ProcessedEvents[event.Id] {
+		s.logger(ctx).Sugar().Debugf("Payment event %s already processed", event.Id)
+		return nil
+	}
+
+	order := s.OrderBook.OrdersByPaymentId[event.PaymentId]
+	if order == nil {
+		// Not marked as processed so the provider retries once the order exists
+		return fmt.Errorf("%w: %s", ErrPaymentNotFound, event.PaymentId)
+	}
+	s.OrderBook.ProcessedEvents[event.Id] = true
+
+	// Events may arrive out of order, so never let an older event override a newer one. Only the provider's
+	// timestamps are compared, the engine's clock may be skewed from the provider's.
+	if event.CreatedAt.Before(order.PaymentEventAt) {
+		s.logger(ctx).Sugar().Debugf("Ignoring stale payment event %s for order %s", event.Id, order.Id)
+		return nil
+	}
+	if order.Status == status {
+		order.PaymentEventAt = event.CreatedAt
+		return nil
+	}
+	if !canTransition(order.Status, status) {
+		s.logger(ctx).Sugar().Debugf("Ignoring payment event %s: order %s can't move from %s to %s", event.Id, order.Id, order.Status, status)
+		return nil
+	}
+
+	// Give back what was spent on orders that won't be paid for, releasing the stock if the payment never went through
+	if status == OrderStatusFailed || status == OrderStatusRefunded {
+		s.releaseOrder(ctx, order, status == OrderStatusFailed)
+	}
+
+	before := order.Status
+	order.Status = status
+	order.StatusUpdatedAt = s.clock().UTC()
+	order.PaymentEventAt = event.CreatedAt
+	s.notifyOrderStatus(ctx, order)
+	// Status changes are made on behalf of the payment provider
+	s.audit(WithActor(ctx, paymentProviderActor), AuditOrderStatusChanged, order.Id,
+		map[string]interface{}{"status": before},
+		map[string]interface{}{"status": status, "event_id": event.Id})
+	s.logger(ctx).Sugar().Infof("Order %s moved to status %s by payment event %s", order.Id, status, event.Id)
+	return nil
+}
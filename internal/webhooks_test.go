@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to build a signed payment event payload
+func signedEvent(t *testing.T, secret string, event PaymentEvent) ([]byte, string) {
+	payload, err := json.Marshal(event)
+	assert.NoError(t, err)
+	return payload, SignWebhookPayload(secret, payload)
+}
+
+// Helper function to place a paid order
+func createPaidOrder(t *testing.T, shoppingApp *shoppingEngine) (*order, *product) {
+	user, p1 := createUserWithCart(t, shoppingApp)
+	order, err := shoppingApp.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	return order, p1
+}
+
+// Test a valid refund event updates the order
+func TestHandlePaymentWebhook_Refunded(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventRefunded, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+
+	// Act
+	err := shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, OrderStatusRefunded, order.Status)
+}
+
+// Test events with a bad signature are rejected
+func TestHandlePaymentWebhook_InvalidSignature(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	payload, _ := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventRefunded, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+
+	// Act
+	err := shoppingApp.HandlePaymentWebhook(context.Background(), payload, SignWebhookPayload("wrong-secret", payload))
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	assert.Equal(t, OrderStatusPaid, order.Status)
+}
+
+// Test duplicate events are applied only once
+func TestHandlePaymentWebhook_Duplicate(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, p1 := createPaidOrder(t, shoppingApp)
+	order.Status = OrderStatusAuthorized
+
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventFailed, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+
+	// Act
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature))
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature))
+
+	// Assert
+	assert.Equal(t, OrderStatusFailed, order.Status)
+	assert.Equal(t, 10, p1.Quantity) // Stock is released only once
+}
+
+// Test an older event arriving late doesn't override a newer status
+func TestHandlePaymentWebhook_OutOfOrder(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+	order.Status = OrderStatusAuthorized
+	now := time.Now()
+
+	refunded, refundedSignature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_2", Type: PaymentEventRefunded, PaymentId: order.PaymentId, CreatedAt: now.Add(time.Minute),
+	})
+	succeeded, succeededSignature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventSucceeded, PaymentId: order.PaymentId, CreatedAt: now,
+	})
+
+	// Act
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), refunded, refundedSignature))
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), succeeded, succeededSignature))
+
+	// Assert
+	assert.Equal(t, OrderStatusRefunded, order.Status)
+}
+
+// Test events for unknown payments are not acknowledged
+func TestHandlePaymentWebhook_UnknownPayment(t *testing.T) {
+	shoppingApp := createMockEngine()
+
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventSucceeded, PaymentId: "pay_404", CreatedAt: time.Now(),
+	})
+
+	// Act
+	err := shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	assert.False(t, shoppingApp.OrderBook.ProcessedEvents["evt_1"])
+}
+
+// Test events without a provider timestamp are rejected and left for the provider to retry
+func TestHandlePaymentWebhook_MissingCreatedAt(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventRefunded, PaymentId: order.PaymentId,
+	})
+
+	// Act
+	err := shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidWebhookEvent)
+	assert.False(t, shoppingApp.OrderBook.ProcessedEvents["evt_1"])
+	assert.Equal(t, OrderStatusPaid, order.Status)
+}
+
+// Test provider timestamps behind the engine's clock still apply, only earlier provider events are stale
+func TestHandlePaymentWebhook_ProviderClockSkew(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+	providerNow := order.StatusUpdatedAt.Add(-time.Hour)
+
+	disputed, disputedSignature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventDisputed, PaymentId: order.PaymentId, CreatedAt: providerNow,
+	})
+	stale, staleSignature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_0", Type: PaymentEventSucceeded, PaymentId: order.PaymentId, CreatedAt: providerNow.Add(-time.Minute),
+	})
+
+	// Act
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), disputed, disputedSignature))
+	assert.NoError(t, shoppingApp.HandlePaymentWebhook(context.Background(), stale, staleSignature))
+
+	// Assert
+	assert.Equal(t, OrderStatusDisputed, order.Status)
+	assert.Equal(t, providerNow.UTC(), order.PaymentEventAt.UTC())
+}
+
+// Test refunded orders no longer count towards the platform analytics
+func TestHandlePaymentWebhook_RefundRemovedFromAnalytics(t *testing.T) {
+	shoppingApp := createMockEngine()
+	order, _ := createPaidOrder(t, shoppingApp)
+
+	payload, signature := signedEvent(t, shoppingApp.WebhookSecret, PaymentEvent{
+		Id: "evt_1", Type: PaymentEventRefunded, PaymentId: order.PaymentId, CreatedAt: time.Now(),
+	})
+
+	// Act
+	err := shoppingApp.HandlePaymentWebhook(context.Background(), payload, signature)
+
+	// Assert
+	assert.NoError(t, err)
+	items, amount, _, _ := shoppingApp.OrderBook.GetAnalytics()
+	assert.Equal(t, 0, items)
+	assert.Equal(t, 0.0, amount)
+}
@@ -1,33 +1,195 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/signal"
+	"syscall"
 	"log"
+	"net"
+	"net/http"
 	"github.com/gin-gonic/gin"
+	"github.com/ecommerce-store/config"
 	"github.com/ecommerce-store/internal"
 	"github.com/ecommerce-store/routes"
+	"github.com/ecommerce-store/rpc"
+	"github.com/ecommerce-store/utilities"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
+// Name the service reports in logs and traces
+const serviceName = "eCommerce-store"
+
 func main() {
-	// Get App instance
-	instance := internal.GetAppInstance()
+	// Cancelled on SIGINT or SIGTERM to start the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Load the config file, env and flags, refusing to start with an invalid configuration
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logger, err := utilities.Logger.Configure(cfg.LoggerConfig(serviceName))
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	internal.Logger = logger
+
+	// Set up tracing before anything creates spans
+	shutdownTracing, err := utilities.SetupTracing(context.Background(), cfg.Tracing.Exporter, serviceName)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// Create an engine per store and restore their state if state files are configured
+	stores := newStores(cfg, logger)
+	for _, s := range stores {
+		if err := s.engine.LoadState(ctx); err != nil {
+			log.Fatalf("Failed to restore state of %s: %v", s.name, err)
+		}
+	}
+
+	// Initialize gin Router, access lines are logged by the request logger
+	route := gin.New()
+	route.Use(gin.Recovery())
+
+	// Register routes, tenants are resolved from the host or path prefix of each request
+	if len(cfg.Tenants) == 0 {
+		routes.RegisterRoutes(route, stores[0].engine, cfg)
+	} else {
+		engines := make(map[string]internal.ShoppingEngine)
+		for _, s := range stores {
+			engines[s.tenant] = s.engine
+		}
+		routes.RegisterTenantRoutes(route, cfg, engines)
+	}
+
+	// No write timeout, event streams stay open for as long as the client listens
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           route,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Duration,
+	}
+	for _, s := range stores {
+		if s.grpcPort == "" {
+			continue
+		}
+		listener, err := net.Listen("tcp", ":"+s.grpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen on gRPC port of %s: %v", s.name, err)
+		}
+		s.listener = listener
+		s.grpcServer = rpc.NewServer(s.engine)
+	}
+
+	// Serve HTTP and gRPC until a signal arrives or any server fails
+	serveErrors := make(chan error, len(stores)+1)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErrors <- err
+		}
+	}()
+	internal.Logger.Sugar().Infof("Serving HTTP on :%s", cfg.Server.Port)
+	for _, s := range stores {
+		if s.grpcServer == nil {
+			continue
+		}
+		s := s
+		go func() {
+			if err := s.grpcServer.Serve(s.listener); err != nil {
+				serveErrors <- err
+			}
+		}()
+		internal.Logger.Sugar().Infof("Serving gRPC of %s on :%s", s.name, s.grpcPort)
+	}
+
+	select {
+	case <-ctx.Done():
+		internal.Logger.Sugar().Info("Shutting down")
+	case err = <-serveErrors:
+		internal.Logger.Sugar().Errorf("Failed to serve: %v", err)
+	}
+	stop()
 
-	// Initialize gin Router
-	route := gin.Default()
+	// End event streams first, open streams would otherwise hold the connections until the timeout
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	for _, s := range stores {
+		s.engine.Close(drainCtx)
+	}
 
-	// Register routes
-	routes.RegisterRoutes(route, instance)
+	// Stop accepting requests and let in-flight ones, such as checkouts, finish
+	if shutdownErr := server.Shutdown(drainCtx); shutdownErr != nil {
+		internal.Logger.Sugar().Errorf("Failed to drain HTTP requests: %v", shutdownErr)
+	}
+	for _, s := range stores {
+		if s.grpcServer != nil {
+			stopGRPC(drainCtx, s.grpcServer)
+		}
+	}
 
-	// Loading port from env
-	port := os.Getenv("PORT")
-	if port == "" {
-		// Default value
-		port = "8080"
+	// Persist the state once nothing can change it anymore
+	for _, s := range stores {
+		if saveErr := s.engine.SaveState(context.Background()); saveErr != nil {
+			internal.Logger.Sugar().Errorf("Failed to save state of %s: %v", s.name, saveErr)
+		}
 	}
-	
-	// Listen and serve on the specified port
-	err := route.Run(":" + port)
+	if tracingErr := shutdownTracing(context.Background()); tracingErr != nil {
+		internal.Logger.Sugar().Errorf("Failed to flush traces: %v", tracingErr)
+	}
+	internal.Logger.Sugar().Info("Shutdown complete")
+	utilities.Logger.Sync()
+
 	if err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// store is an engine served by the process, with its gRPC server if it has a port
+type store struct {
+	name       string
+	tenant     string
+	engine     internal.ShoppingEngine
+	grpcPort   string
+	listener   net.Listener
+	grpcServer *grpc.Server
+}
+
+// newStores creates the engine of each tenant, or a single engine when no tenants are configured
+func newStores(cfg *config.Config, logger *zap.Logger) []*store {
+	if len(cfg.Tenants) == 0 {
+		return []*store{{
+			name:     "the store",
+			engine:   internal.NewEngine(cfg, internal.WithDefaultLogger(logger)),
+			grpcPort: cfg.Server.GRPCPort,
+		}}
+	}
+	stores := make([]*store, 0, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		stores = append(stores, &store{
+			name:     "tenant " + tenant.Id,
+			tenant:   tenant.Id,
+			engine:   internal.NewEngine(cfg.ForTenant(tenant), internal.WithDefaultLogger(logger), internal.WithTenant(tenant.Id)),
+			grpcPort: tenant.GRPCPort,
+		})
+	}
+	return stores
+}
+
+// stopGRPC waits for in-flight RPCs to finish, cancelling the remaining ones once ctx is done
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		internal.Logger.Sugar().Error("Timed out draining RPCs, cancelling the rest")
+		server.Stop()
+	}
+}
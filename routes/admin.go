@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// requireAdminToken rejects requests without the admin token as a bearer token, the routes stay open if no token is configured
+func requireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		given, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			errorResponse(c, 401, codeUnauthorized, "Missing or invalid admin token")
+			c.Abort()
+			return
+		}
+		if c.GetHeader("X-User-Id") == "" {
+			// The token is the only identity the request proved
+			c.Request = c.Request.WithContext(internal.WithActor(c.Request.Context(), internal.AdminTokenActor))
+		}
+		c.Next()
+	}
+}
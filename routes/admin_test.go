@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecommerce-store/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test admin routes require the configured token while other routes stay open
+func TestAdminRoutes_RequireToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.AdminToken = "admin-secret"
+	router := createConfiguredRouter(cfg)
+	request := func(path string, token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Act
+	missing := request("/v1/admin/analytics", "")
+	wrong := request("/v1/admin/analytics", "guess")
+	legacy := request("/admin/analytics", "")
+	valid := request("/v1/admin/analytics", "admin-secret")
+	public := request("/v1/products/unknown", "")
+
+	// Assert
+	assert.Equal(t, 401, missing.Code)
+	assert.Contains(t, missing.Body.String(), `"code":"unauthorized"`)
+	assert.Equal(t, `Bearer realm="admin"`, missing.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, 401, wrong.Code)
+	assert.Equal(t, 401, legacy.Code)
+	assert.Equal(t, 200, valid.Code)
+	assert.Equal(t, 404, public.Code)
+}
+
+// Test seller routes require the admin token as well as the claimed seller id
+func TestSellerRoutes_RequireToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.AdminToken = "admin-secret"
+	router := createConfiguredRouter(cfg)
+	request := func(callerId string, token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/sellers/seller-1/analytics", nil)
+		req.Header.Set("X-User-Id", callerId)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Act
+	claimedOnly := request("seller-1", "")
+	mismatched := request("seller-2", "admin-secret")
+	valid := request("seller-1", "admin-secret")
+
+	// Assert
+	assert.Equal(t, 401, claimedOnly.Code)
+	assert.Equal(t, 403, mismatched.Code)
+	assert.Equal(t, 404, valid.Code) // Seller doesn't exist
+}
+
+// Test actions authorised by the admin token alone are attributed to the token
+func TestAdminRoutes_AuditTokenActor(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.AdminToken = "admin-secret"
+	router := createConfiguredRouter(cfg)
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Act
+	updated := request(http.MethodPut, "/v1/admin/coupon-policy", `{"type":"first_order"}`)
+	audit := request(http.MethodGet, "/v1/admin/audit?action=coupon_policy.changed", "")
+
+	// Assert
+	assert.Equal(t, 200, updated.Code)
+	assert.Equal(t, 200, audit.Code)
+	assert.Contains(t, audit.Body.String(), `"actor":"admin-token"`)
+	assert.NotContains(t, audit.Body.String(), `"actor_claimed"`)
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// auditActor attributes the engine actions of a request to the user in the X-User-Id header.
+// The header isn't authenticated, so the audit entries mark the actor as claimed.
+func auditActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := c.GetHeader("X-User-Id"); actor != "" {
+			setActor(c, actor)
+		}
+		c.Next()
+	}
+}
+
+// setActor attributes the engine actions of the request to the claimed actor, for handlers that read it from the body
+func setActor(c *gin.Context, actor string) {
+	c.Request = c.Request.WithContext(internal.WithClaimedActor(c.Request.Context(), actor))
+}
+
+// parseAuditFilter parses the actor, action, target, from, to and limit query parameters, responding with 400 if invalid.
+// The limit defaults to defaultLimit, 0 returns every matching entry.
+func parseAuditFilter(c *gin.Context, defaultLimit int) (internal.AuditFilter, bool) {
+	filter := internal.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+	}
+	var err error
+	if filter.From, err = parseTime(c.Query("from"), time.UTC, time.Time{}); err != nil {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid start date")
+		return filter, false
+	}
+	if filter.To, err = parseTime(c.Query("to"), time.UTC, time.Time{}); err != nil {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid end date")
+		return filter, false
+	}
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || filter.Limit < 0 || filter.Limit > 1000 {
+		errorResponse(c, 400, codeInvalidRequest, "Limit must be between 0 and 1000")
+		return filter, false
+	}
+	return filter, true
+}
+
+func registerAuditRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
+	rg.GET("/audit", func(c *gin.Context) {
+		filter, ok := parseAuditFilter(c, 100)
+		if !ok {
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Audit log retrieved successfully",
+			"data":    gin.H{
+				"entries": svc.GetAuditLog(c.Request.Context(), filter),
+			},
+		})
+	})
+
+	rg.GET("/audit/export", func(c *gin.Context) {
+		// Export every matching entry unless a limit is given
+		filter, ok := parseAuditFilter(c, 0)
+		if !ok {
+			return
+		}
+
+		// One JSON entry per line, oldest first, so the chain can be verified offline
+		c.Header("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(200)
+		encoder := json.NewEncoder(c.Writer)
+		for _, entry := range svc.GetAuditLog(c.Request.Context(), filter) {
+			if err := encoder.Encode(entry); err != nil {
+				internal.LoggerFrom(c.Request.Context()).Sugar().Errorf("Unable to export audit log: %v", err)
+				return
+			}
+		}
+	})
+
+	rg.GET("/audit/verify", func(c *gin.Context) {
+		// Recompute the hash chain
+		if err := svc.VerifyAuditLog(c.Request.Context()); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response with the head to record outside the store
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Audit log is intact",
+			"data":    gin.H{
+				"head": svc.GetAuditHead(c.Request.Context()),
+			},
+		})
+	})
+}
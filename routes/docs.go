@@ -0,0 +1,39 @@
+package routes
+
+import (
+	_ "embed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the OpenAPI 3 document describing every route registered by RegisterRoutes
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders the OpenAPI document with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>eCommerce Store API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+func registerDocsRoutes(router gin.IRoutes) {
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(200, "application/json", openAPISpec)
+	})
+
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(200, "text/html; charset=utf-8", []byte(docsPage))
+	})
+}
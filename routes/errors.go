@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"errors"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in the "code" field of error responses
+const (
+	codeInvalidRequest           = "invalid_request"
+	codeUnauthorized             = "unauthorized"
+	codeForbidden                = "forbidden"
+	codeNotFound                 = "not_found"
+	codeConflict                 = "conflict"
+	codeValidationFailed         = "validation_failed"
+	codeInsufficientStock        = "insufficient_stock"
+	codeCouponInvalid            = "coupon_invalid"
+	codeCouponExpired            = "coupon_expired"
+	codePaymentDeclined          = "payment_declined"
+	codeIdempotencyKeyReused     = "idempotency_key_reused"
+	codeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	codeInvalidSignature         = "invalid_signature"
+	codeAuditChainBroken         = "audit_chain_broken"
+	codeRateLimited              = "rate_limited"
+	codeInternal                 = "internal_error"
+)
+
+// errorMapping maps an engine error to a response status and code
+type errorMapping struct {
+	err    error  // Error matched with errors.Is
+	status int    // HTTP status of the response
+	code   string // Code returned in the response
+}
+
+// errorMappings is checked in order, so specific errors must come before the kind they wrap
+var errorMappings = []errorMapping{
+	{internal.ErrIdempotencyKeyReused, 422, codeIdempotencyKeyReused},
+	{internal.ErrIdempotencyKeyInProgress, 409, codeIdempotencyKeyInProgress},
+	{internal.ErrInvalidWebhookSignature, 401, codeInvalidSignature},
+	{internal.ErrPaymentDeclined, 402, codePaymentDeclined},
+	{internal.ErrAuditChainBroken, 500, codeAuditChainBroken},
+	{internal.ErrNotFound, 404, codeNotFound},
+	{internal.ErrConflict, 409, codeConflict},
+	{internal.ErrInsufficientStock, 409, codeInsufficientStock},
+	{internal.ErrCouponInvalid, 422, codeCouponInvalid},
+	{internal.ErrCouponExpired, 422, codeCouponExpired},
+	{internal.ErrValidation, 400, codeValidationFailed},
+}
+
+// mapError returns the response status and code for an engine error
+func mapError(err error) (int, string) {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return mapping.status, mapping.code
+		}
+	}
+	return 500, codeInternal
+}
+
+// respondError writes the error envelope for an engine error
+func respondError(c *gin.Context, err error) {
+	status, code := mapError(err)
+	if status == 500 {
+		internal.LoggerFrom(c.Request.Context()).Sugar().Errorf("Request failed: %v", err)
+	}
+	errorResponse(c, status, code, err.Error())
+}
+
+// errorResponse writes the error envelope with the given status, code and message
+func errorResponse(c *gin.Context, status int, code string, message string) {
+	c.JSON(status, gin.H{
+		"status":  "error",
+		"code":    code,
+		"message": message,
+	})
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// Interval between comments sent to keep idle event streams open through proxies
+const eventKeepAlive = 15 * time.Second
+
+// streamEvents streams the events selected by the filter as Server-Sent Events until the client disconnects.
+// Clients resume after the last event they received with the Last-Event-ID header, or last_event_id for the first connection.
+func streamEvents(c *gin.Context, svc internal.ShoppingEngine, filter internal.EventFilter) {
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.DefaultQuery("last_event_id", "0")
+	}
+	after, err := strconv.ParseUint(lastEventId, 10, 64)
+	if err != nil {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid last event ID")
+		return
+	}
+
+	events := svc.SubscribeEvents(c.Request.Context(), filter, after)
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	c.Status(200)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Dropped for falling behind, the client reconnects and resumes from the last event it received
+				return false
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				internal.LoggerFrom(c.Request.Context()).Sugar().Errorf("Unable to encode event %d: %v", event.Id, err)
+				return true
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
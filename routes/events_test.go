@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to open an event stream and return its reader
+func openEventStream(t *testing.T, ctx context.Context, url string, callerId string, lastEventId string) *bufio.Reader {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	request.Header.Set("X-User-Id", callerId)
+	if lastEventId != "" {
+		request.Header.Set("Last-Event-ID", lastEventId)
+	}
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	t.Cleanup(func() { response.Body.Close() })
+	return bufio.NewReader(response.Body)
+}
+
+// Helper function to read the next event from a stream, skipping keep-alive comments
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && len(fields) > 0 {
+			return fields
+		}
+		if name, value, ok := strings.Cut(line, ": "); ok && name != "" {
+			fields[name] = value
+		}
+	}
+}
+
+// Test users receive their order as it is placed and can resume after a reconnect
+func TestUserEvents_StreamAndResume(t *testing.T) {
+	svc := internal.NewEngine(nil)
+	server := httptest.NewServer(createTestRouter(svc))
+	defer server.Close()
+
+	seller, err := svc.RegisterUser(context.Background(), "Seller", uuid.NewString()+"@example.com")
+	assert.NoError(t, err)
+	product, err := svc.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10.0)
+	assert.NoError(t, err)
+	user, err := svc.RegisterUser(context.Background(), "Buyer", uuid.NewString()+"@example.com")
+	assert.NoError(t, err)
+	_, err = svc.AddToCart(context.Background(), user.Id, product.Id, 1)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := openEventStream(t, ctx, server.URL+"/v1/users/"+user.Id+"/events", user.Id, "")
+
+	// Act
+	order, err := svc.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+	placed := readEvent(t, stream)
+	_, err = svc.CancelOrder(context.Background(), user.Id, order.Id)
+	assert.NoError(t, err)
+	resumed := readEvent(t, openEventStream(t, ctx, server.URL+"/v1/users/"+user.Id+"/events", user.Id, placed["id"]))
+
+	// Assert
+	assert.Equal(t, internal.EventOrderPlaced, placed["event"])
+	assert.Contains(t, placed["data"], order.Id)
+	assert.Contains(t, placed["data"], `"status":"paid"`)
+	assert.Equal(t, internal.EventOrderStatusChanged, resumed["event"])
+	assert.Contains(t, resumed["data"], `"status":"cancelled"`)
+}
+
+// Test users may only follow their own events
+func TestUserEvents_Forbidden(t *testing.T) {
+	svc := internal.NewEngine(nil)
+	user, err := svc.RegisterUser(context.Background(), "Buyer", "buyer@example.com")
+	assert.NoError(t, err)
+	router := createTestRouter(svc)
+	request := func(callerId string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/"+user.Id+"/events", nil)
+		if callerId != "" {
+			req.Header.Set("X-User-Id", callerId)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// Act
+	missing := request("")
+	mismatched := request("someone-else")
+
+	// Assert
+	assert.Equal(t, 401, missing.Code)
+	assert.Equal(t, 403, mismatched.Code)
+}
+
+// Test sellers may only follow their own events
+func TestSellerEvents_Forbidden(t *testing.T) {
+	router := createTestRouter(internal.NewEngine(nil))
+	request := httptest.NewRequest(http.MethodGet, "/v1/sellers/seller-1/events", nil)
+	request.Header.Set("X-User-Id", "seller-2")
+
+	// Act
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	// Assert
+	assert.Equal(t, 403, response.Code)
+}
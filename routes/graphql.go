@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"github.com/ecommerce-store/graph"
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+func registerGraphQLRoutes(router *gin.Engine, svc internal.ShoppingEngine) {
+	executor := graph.NewExecutor(svc)
+
+	router.POST("/graphql", func(c *gin.Context) {
+		// Expected request body
+		var request struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Query == "" {
+			// GraphQL clients expect errors in the GraphQL response format
+			c.JSON(400, gin.H{"errors": []gin.H{{"message": "Invalid request"}}})
+			return
+		}
+
+		// Errors from resolvers are reported in the response alongside any partial data, the order and cart resolvers
+		// only act for the user claimed in X-User-Id
+		ctx := graph.WithCaller(c.Request.Context(), c.GetHeader("X-User-Id"))
+		c.JSON(200, executor.Execute(ctx, request.Query, request.OperationName, request.Variables))
+	})
+}
@@ -2,32 +2,50 @@ package routes
 
 import (
 	"net/mail"
+	"strconv"
+	"time"
+	"github.com/ecommerce-store/config"
 	"github.com/ecommerce-store/internal"
+	"github.com/ecommerce-store/utilities"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// Name the service reports in traces
+const serviceName = "eCommerce-store"
+
+// RegisterRoutes serves a single store backed by the engine
+func RegisterRoutes(router *gin.Engine, svc internal.ShoppingEngine, cfg *config.Config) {
+	// Trace, record metrics, log and rate limit every route registered below
+	router.Use(requestMiddleware(rateLimit(cfg.RateLimit))...)
+	registerMetricsRoutes(router)
+	registerInventoryMetrics(svc, "")
+	registerDocsRoutes(router)
+	registerStoreRoutes(router, svc, cfg)
+}
 
-func RegisterRoutes(router *gin.Engine, svc internal.ShoppingEngine) {
-	admin := router.Group("/admin")
-	registerAdminRoutes(admin, svc)
-
-	auth := router.Group("/auth")
-	registerAuthRoutes(auth, svc)
+// requestMiddleware traces, records metrics, logs and rate limits the requests of a store
+func requestMiddleware(limit gin.HandlerFunc) []gin.HandlerFunc {
+	return []gin.HandlerFunc{otelgin.Middleware(serviceName), metricsMiddleware(), requestLogger(), limit, auditActor()}
+}
 
-	users := router.Group("/users")
-	registerUserRoutes(users, svc)
+// registerStoreRoutes registers the API of a store
+func registerStoreRoutes(router *gin.Engine, svc internal.ShoppingEngine, cfg *config.Config) {
+	registerGraphQLRoutes(router, svc)
 
-	orders := router.Group("/orders")
-	registerOrderRoutes(orders, svc)
+	// Current version of the API
+	registerV1Routes(router.Group("/v1"), svc, cfg)
 
-	products := router.Group("/products")
-	registerProductRoutes(products, svc)
+	// Unversioned paths predate /v1 and are kept as deprecated aliases until their sunset
+	registerV1Routes(router.Group("", deprecated(legacyDeprecatedAt, legacySunset, "/v1")), svc, cfg)
 }
 
 func registerAdminRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
+	registerAuditRoutes(rg, svc)
+
 	rg.GET("/analytics", func(c *gin.Context) {
 		// Get Order analytics
-		items, amount, discount, coupons := svc.OrderHistory().GetAnalytics()
+		items, amount, discount, coupons := svc.OrderHistory(c.Request.Context()).GetAnalytics()
 		c.JSON(200, gin.H{
 			"status":  	"success",
 			"message": 	"Platform analytics retrieved successfully",
@@ -35,7 +53,253 @@ func registerAdminRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 				"total_items_sold": items,
 				"total_purchase_amount": amount,
 				"total_discount": discount,
-				"applied_coupons": coupons,
+				"coupon_redemptions": coupons,
+			},
+		})
+	})
+
+	rg.GET("/analytics/timeseries", func(c *gin.Context) {
+		// Parse the timezone the buckets are aligned to (e.g., ?timezone=Asia/Kolkata)
+		location, err := time.LoadLocation(c.DefaultQuery("timezone", "UTC"))
+		if err != nil {
+			errorResponse(c, 400, codeInvalidRequest, "Invalid timezone")
+			return
+		}
+
+		// Parse the date range, defaulting to the last 30 days
+		to, err := parseTime(c.Query("to"), location, time.Now())
+		if err != nil {
+			errorResponse(c, 400, codeInvalidRequest, "Invalid end date")
+			return
+		}
+		from, err := parseTime(c.Query("from"), location, to.AddDate(0, 0, -30))
+		if err != nil {
+			errorResponse(c, 400, codeInvalidRequest, "Invalid start date")
+			return
+		}
+
+		// Aggregate the orders
+		interval := c.DefaultQuery("interval", internal.BucketDay)
+		buckets, err := svc.OrderHistory(c.Request.Context()).GetTimeseries(from, to, interval, location)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Sales timeseries retrieved successfully",
+			"data":    gin.H{
+				"from":     from,
+				"to":       to,
+				"interval": interval,
+				"timezone": location.String(),
+				"buckets":  buckets,
+			},
+		})
+	})
+
+	rg.GET("/analytics/top-products", func(c *gin.Context) {
+		from, to, limit, ok := parseReportParams(c)
+		if !ok {
+			return
+		}
+
+		// Rank the products (e.g., ?by=revenue)
+		products, err := svc.OrderHistory(c.Request.Context()).GetTopProducts(from, to, limit, c.DefaultQuery("by", internal.RankByUnits))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Top products retrieved successfully",
+			"data":    gin.H{
+				"products": products,
+			},
+		})
+	})
+
+	rg.GET("/analytics/top-customers", func(c *gin.Context) {
+		from, to, limit, ok := parseReportParams(c)
+		if !ok {
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Top customers retrieved successfully",
+			"data":    gin.H{
+				"customers": svc.OrderHistory(c.Request.Context()).GetTopCustomers(from, to, limit),
+			},
+		})
+	})
+
+	rg.GET("/analytics/coupons", func(c *gin.Context) {
+		from, to, limit, ok := parseReportParams(c)
+		if !ok {
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Coupon report retrieved successfully",
+			"data":    gin.H{
+				"coupons": svc.OrderHistory(c.Request.Context()).GetCouponReport(from, to, limit),
+			},
+		})
+	})
+
+	rg.GET("/sellers/:seller_id/analytics", func(c *gin.Context) {
+		// Admins may view the analytics of any seller
+		sellerAnalyticsHandler(c, svc)
+	})
+
+	rg.PUT("/metrics/watched-products/:product_id", func(c *gin.Context) {
+		// Export the stock of the product on /metrics
+		productId := c.Param("product_id")
+		if err := svc.WatchProduct(c.Request.Context(), productId); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Product stock is now exported as a metric",
+			"data":    gin.H{
+				"stock": svc.GetWatchedStock(c.Request.Context()),
+			},
+		})
+	})
+
+	rg.DELETE("/metrics/watched-products/:product_id", func(c *gin.Context) {
+		// Stop exporting the stock of the product
+		svc.UnwatchProduct(c.Request.Context(), c.Param("product_id"))
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Product stock is no longer exported as a metric",
+			"data":    gin.H{
+				"stock": svc.GetWatchedStock(c.Request.Context()),
+			},
+		})
+	})
+
+	rg.GET("/log-level", func(c *gin.Context) {
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Log level retrieved successfully",
+			"data":    gin.H{
+				"level": utilities.Logger.GetLevel(),
+			},
+		})
+	})
+
+	rg.PUT("/log-level", func(c *gin.Context) {
+		// Expected request body
+		var request struct {
+			Level string `json:"level" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			// Invalid request body
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
+			return
+		}
+
+		// Change the level without restarting the service
+		before := utilities.Logger.GetLevel()
+		if err := utilities.Logger.SetLevel(request.Level); err != nil {
+			errorResponse(c, 400, codeValidationFailed, err.Error())
+			return
+		}
+		svc.RecordAudit(c.Request.Context(), internal.AuditLogLevelChanged, "logger", gin.H{"level": before}, gin.H{"level": utilities.Logger.GetLevel()})
+		internal.LoggerFrom(c.Request.Context()).Sugar().Infof("Log level changed to %s", request.Level)
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Log level updated successfully",
+			"data":    gin.H{
+				"level": utilities.Logger.GetLevel(),
+			},
+		})
+	})
+
+	rg.GET("/coupon-policy", func(c *gin.Context) {
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Coupon policy retrieved successfully",
+			"data":    gin.H{
+				"policy": svc.GetCouponPolicy(c.Request.Context()).Spec(),
+			},
+		})
+	})
+
+	rg.PUT("/coupon-policy", func(c *gin.Context) {
+		// Expected request body
+		var request internal.CouponPolicySpec
+		if err := c.ShouldBindJSON(&request); err != nil {
+			// Invalid request body
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
+			return
+		}
+
+		// Build and validate the policy
+		policy, err := internal.NewCouponPolicy(request)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		svc.SetCouponPolicy(c.Request.Context(), policy)
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Coupon policy updated successfully",
+			"data":    gin.H{
+				"policy": policy.Spec(),
+			},
+		})
+	})
+
+	rg.POST("/users/:user_id/store-credit", func(c *gin.Context) {
+		// Parse user id from the URL parameters (e.g., /users/:user_id/store-credit)
+		userId := c.Param("user_id")
+
+		// Expected request body
+		var request struct {
+			Amount    float64 `json:"amount"`
+			Reference string  `json:"reference"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Amount <= 0 {
+			// Invalid request body
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
+			return
+		}
+
+		// Credit the user's wallet
+		wallet, err := svc.IssueStoreCredit(c.Request.Context(), userId, request.Amount, request.Reference)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Store credit issued successfully",
+			"data":    gin.H{
+				"user_id": userId,
+				"wallet":  wallet,
 			},
 		})
 	})
@@ -50,29 +314,20 @@ func registerAuthRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 	
 		if err := c.ShouldBindJSON(&request); err != nil {
 			// Invalid request body
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid request",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
 			return
 		}
 
 		// Check if username is empty
 		if request.Username == "" {
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Username is required",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Username is required")
 			return
 		}
 
 		// Check if the username is valid
-		user, err := svc.GetUserByUsername(request.Username)
+		user, err := svc.GetUserByUsername(c.Request.Context(), request.Username)
 		if err != nil {
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 
@@ -99,40 +354,28 @@ func registerAuthRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 	
 		if err := c.ShouldBindJSON(&request); err != nil {
 			// Invalid request body
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid request",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
 			return
 		}
 		
 		// Check if name or email is empty
 		if request.Email == "" || request.Name == "" {
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Name and email are required",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Name and email are required")
 			return
 		}
 
 		// Validate email format
 		_, err := mail.ParseAddress(request.Email)
 		if err != nil {
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid email format",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid email format")
 			return
 		}
 
 		// Register user
-		user, err := svc.RegisterUser(request.Name, request.Email)
+		user, err := svc.RegisterUser(c.Request.Context(), request.Name, request.Email)
 		if err != nil {
 			// Failed to register user
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 
@@ -160,20 +403,14 @@ func registerUserRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 		userId := c.Param("user_id")
 		if userId == "" {
 			// If userId is empty, return a bad request error
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "User ID cannot be empty",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "User ID cannot be empty")
 			return
 		}
 
 		// Get discount coupon for the user
-		coupon, err := svc.GetDiscountCoupon(userId)
+		coupon, err := svc.GetDiscountCoupon(c.Request.Context(), userId)
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 
@@ -192,10 +429,7 @@ func registerUserRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 		userId := c.Param("user_id")
 		if userId == "" {
 			// If userId is empty, return a bad request error
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "User ID cannot be empty",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "User ID cannot be empty")
 			return
 		}
 
@@ -207,20 +441,14 @@ func registerUserRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 	
 		if err := c.ShouldBindJSON(&cartItem); err != nil {
 			// Invalid request body
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid request",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
 			return
 		}
 
 		// Add product to cart
-		cartMap, err := svc.AddToCart(userId, cartItem.ProductId, cartItem.Quantity)
+		cartMap, err := svc.AddToCart(c.Request.Context(), userId, cartItem.ProductId, cartItem.Quantity)
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 		var updatedCart []gin.H
@@ -247,20 +475,14 @@ func registerUserRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 		userId := c.Param("user_id")
 		if userId == "" {
 			// If userId is empty, return a bad request error
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "User ID cannot be empty",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "User ID cannot be empty")
 			return
 		}
 
 		// Get cart details for the user
-		cartMap, err := svc.GetCart(userId)
+		cartMap, err := svc.GetCart(c.Request.Context(), userId)
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 		var updatedCart []gin.H
@@ -281,6 +503,168 @@ func registerUserRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 			},
 		})
 	})
+
+	user.GET("/wallet", func(c *gin.Context) {
+		// Users may only view their own wallet
+		userId := c.Param("user_id")
+		if !requireCaller(c, userId, "Users can only view their own wallet") {
+			return
+		}
+
+		// Get the wallet of the user
+		wallet, err := svc.GetWallet(c.Request.Context(), userId)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Wallet retrieved successfully",
+			"data":    gin.H{
+				"user_id": userId,
+				"wallet":  wallet,
+			},
+		})
+	})
+
+	user.POST("/wallet/redeem", func(c *gin.Context) {
+		// Users may only redeem gift cards into their own wallet
+		userId := c.Param("user_id")
+		if !requireCaller(c, userId, "Users can only redeem gift cards into their own wallet") {
+			return
+		}
+
+		// Expected request body
+		var request struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+			// Invalid request body
+			errorResponse(c, 400, codeInvalidRequest, "Gift card code is required")
+			return
+		}
+
+		// Redeem the gift card into the wallet
+		wallet, err := svc.RedeemGiftCard(c.Request.Context(), userId, request.Code)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Gift card redeemed successfully",
+			"data":    gin.H{
+				"user_id": userId,
+				"wallet":  wallet,
+			},
+		})
+	})
+
+	user.POST("/gift-cards", func(c *gin.Context) {
+		// Users may only buy gift cards with their own payment method
+		userId := c.Param("user_id")
+		if !requireCaller(c, userId, "Users can only buy gift cards for themselves") {
+			return
+		}
+
+		// Expected request body
+		var request struct {
+			Amount float64 `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Amount <= 0 {
+			// Invalid request body
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
+			return
+		}
+
+		// Purchase the gift card
+		card, err := svc.PurchaseGiftCard(c.Request.Context(), userId, request.Amount)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(201, gin.H{
+			"status":  "success",
+			"message": "Gift card purchased successfully",
+			"data":    gin.H{
+				"gift_card": card,
+			},
+		})
+	})
+
+	user.GET("/points", func(c *gin.Context) {
+		// Users may only view their own points
+		userId := c.Param("user_id")
+		if !requireCaller(c, userId, "Users can only view their own loyalty points") {
+			return
+		}
+
+		// Get the loyalty points of the user
+		points, err := svc.GetPoints(c.Request.Context(), userId)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Loyalty points retrieved successfully",
+			"data":    gin.H{
+				"user_id": userId,
+				"balance": points.Balance,
+				"history": points.History,
+			},
+		})
+	})
+
+	user.GET("/events", func(c *gin.Context) {
+		// Users may only follow their own orders
+		userId := c.Param("user_id")
+		if !requireCaller(c, userId, "Users can only follow their own events") {
+			return
+		}
+
+		// Check the user exists before opening the stream
+		if _, err := svc.GetUser(c.Request.Context(), userId); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Stream the changes to the user's orders
+		streamEvents(c, svc, internal.EventFilter{UserId: userId})
+	})
+
+	user.POST("/orders/:order_id/cancel", func(c *gin.Context) {
+		// Users may only cancel their own orders
+		userId := c.Param("user_id")
+		orderId := c.Param("order_id")
+		if !requireCaller(c, userId, "Users can only cancel their own orders") {
+			return
+		}
+
+		// Cancel the order
+		order, err := svc.CancelOrder(c.Request.Context(), userId, orderId)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Order cancelled successfully",
+			"data":    gin.H{
+				"order": order,
+			},
+		})
+	})
 }
 
 func registerProductRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
@@ -293,35 +677,42 @@ func registerProductRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 			Description string  `json:"description"`
 			Price       float64 `json:"price"`
 			Quantity    int   	`json:"quantity"`
+			Category    string  `json:"category"`
 		}
 	
 		if err := c.ShouldBindJSON(&request); err != nil {
 			// Invalid request body
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid request format",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request format")
 			return
 		}
 	
 		// validate the request
 		if request.UserId == "" || request.Name == "" || request.Price < 0.0 || request.Quantity < 0 {
-			c.JSON(400, gin.H{
-				"status": "error",
-				"message": "Invalid request data",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request data")
 			return
 		}
 	
+		setLogUser(c, request.UserId)
+		if c.GetHeader("X-User-Id") == "" {
+			// Sellers register their own products
+			setActor(c, request.UserId)
+		}
+
 		// Add the product
-		product, err := svc.RegisterProduct(request.Name, request.Description, request.Quantity, request.UserId, request.Price)
+		product, err := svc.RegisterProduct(c.Request.Context(), request.Name, request.Description, request.Quantity, request.UserId, request.Price)
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
+
+		// Categorize the product if a category was given
+		if request.Category != "" {
+			product, err = svc.SetProductCategory(c.Request.Context(), product.Id, request.Category)
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+		}
 		
 		// Successful response
 		c.JSON(201, gin.H{
@@ -338,20 +729,14 @@ func registerProductRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 		productId := c.Param("product_id")
 		if productId == "" {
 			// If productId is empty, return a bad request error
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Product ID cannot be empty",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Product ID cannot be empty")
 			return
 		}
 		
 		// Get the product details
-		product, err := svc.GetProduct(productId)
+		product, err := svc.GetProduct(c.Request.Context(), productId)
 		if err != nil {
-			c.JSON(404, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 		
@@ -366,38 +751,121 @@ func registerProductRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 	})
 }
 
+func registerSellerRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
+
+	seller := rg.Group("/:seller_id")
+
+	seller.GET("/analytics", func(c *gin.Context) {
+		// Access is controlled by the admin token, the claimed seller id only scopes the request
+		if !requireCaller(c, c.Param("seller_id"), "Sellers can only view their own analytics") {
+			return
+		}
+		sellerAnalyticsHandler(c, svc)
+	})
+
+	seller.GET("/events", func(c *gin.Context) {
+		// Access is controlled by the admin token, the claimed seller id only scopes the request
+		sellerId := c.Param("seller_id")
+		if !requireCaller(c, sellerId, "Sellers can only follow their own events") {
+			return
+		}
+		if _, err := svc.GetUser(c.Request.Context(), sellerId); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Stream orders of the seller's products and changes to their stock
+		streamEvents(c, svc, internal.EventFilter{SellerId: sellerId})
+	})
+}
+
+// requireCaller responds with an error unless the caller claims to be the given user in the X-User-Id header.
+// The header is not authenticated, anyone can send any user id. It is a placeholder that keeps honest clients to
+// their own data until users get credentials, routes that need access control must also be behind requireAdminToken.
+func requireCaller(c *gin.Context, userId string, forbidden string) bool {
+	callerId := c.GetHeader("X-User-Id")
+	if callerId == "" {
+		errorResponse(c, 401, codeUnauthorized, "X-User-Id header is required")
+		return false
+	}
+	if callerId != userId {
+		errorResponse(c, 403, codeForbidden, forbidden)
+		return false
+	}
+	return true
+}
+
+// sellerAnalyticsHandler responds with the analytics of the seller in the URL
+func sellerAnalyticsHandler(c *gin.Context, svc internal.ShoppingEngine) {
+	// Parse seller id from the URL parameters (e.g., /:seller_id/analytics)
+	sellerId := c.Param("seller_id")
+
+	// Parse the low stock threshold (e.g., ?low_stock=5)
+	threshold, err := strconv.Atoi(c.DefaultQuery("low_stock", "5"))
+	if err != nil || threshold < 0 {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid low stock threshold")
+		return
+	}
+
+	// Get the seller analytics
+	analytics, err := svc.GetSellerAnalytics(c.Request.Context(), sellerId, threshold)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Successful response
+	c.JSON(200, gin.H{
+		"status":  "success",
+		"message": "Seller analytics retrieved successfully",
+		"data":    gin.H{
+			"analytics": analytics,
+		},
+	})
+}
+
 func registerOrderRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 
 	rg.POST("/checkout", func(c *gin.Context) {
 		// Expected request body
 		var request struct {
-			UserId     string `json:"user_id"`
-			CouponCode string `json:"coupon_code"`
+			UserId       string  `json:"user_id"`
+			CouponCode   string  `json:"coupon_code"`
+			WalletAmount float64 `json:"wallet_amount"`
+			RedeemPoints int     `json:"redeem_points"`
 		}
 		if err := c.ShouldBindJSON(&request); err != nil {
 			// Invalid request body
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "Invalid request",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
 			return
 		}
 		if request.UserId == "" {
 			// If userId is empty, return a bad request error
-			c.JSON(400, gin.H{
-				"status":  "error",
-				"message": "User ID cannot be empty",
-			})
+			errorResponse(c, 400, codeInvalidRequest, "User ID cannot be empty")
 			return
 		}
-		
-		// Call the checkout function
-		order, err := svc.Checkout(request.UserId, request.CouponCode)
+		setLogUser(c, request.UserId)
+
+		options := internal.CheckoutOptions{
+			CouponCode:   request.CouponCode,
+			WalletAmount: request.WalletAmount,
+			RedeemPoints: request.RedeemPoints,
+		}
+
+		// Call the checkout function, at most once per idempotency key if one is supplied
+		var order interface{}
+		var err error
+		if key := c.GetHeader("Idempotency-Key"); key != "" {
+			var replayed bool
+			order, replayed, err = svc.CheckoutWithIdempotencyKey(c.Request.Context(), key, request.UserId, options)
+			if replayed {
+				c.Header("Idempotent-Replayed", "true")
+			}
+		} else {
+			order, err = svc.CheckoutWithOptions(c.Request.Context(), request.UserId, options)
+		}
 		if err != nil {
-			c.JSON(500, gin.H{
-				"status":  "error",
-				"message": err.Error(),
-			})
+			respondError(c, err)
 			return
 		}
 	
@@ -411,3 +879,61 @@ func registerOrderRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
 		})
 	})
 }
+
+
+func registerPaymentRoutes(rg *gin.RouterGroup, svc internal.ShoppingEngine) {
+
+	rg.POST("/webhook", func(c *gin.Context) {
+		// Signature is computed over the raw request body
+		payload, err := c.GetRawData()
+		if err != nil {
+			errorResponse(c, 400, codeInvalidRequest, "Invalid request")
+			return
+		}
+
+		// Verify and apply the payment event
+		err = svc.HandlePaymentWebhook(c.Request.Context(), payload, c.GetHeader("X-Webhook-Signature"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Successful response
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"message": "Payment event processed successfully",
+		})
+	})
+}
+
+// parseTime parses an RFC 3339 timestamp or a date in the given location, returning fallback for empty values
+func parseTime(value string, location *time.Location, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, location)
+}
+
+// parseReportParams parses the from, to and limit query parameters of a report, responding with 400 if invalid.
+// Reports cover all orders up to now and return 10 entries by default.
+func parseReportParams(c *gin.Context) (time.Time, time.Time, int, bool) {
+	to, err := parseTime(c.Query("to"), time.UTC, time.Now())
+	if err != nil {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid end date")
+		return time.Time{}, time.Time{}, 0, false
+	}
+	from, err := parseTime(c.Query("from"), time.UTC, time.Time{})
+	if err != nil {
+		errorResponse(c, 400, codeInvalidRequest, "Invalid start date")
+		return time.Time{}, time.Time{}, 0, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		errorResponse(c, 400, codeInvalidRequest, "Limit must be between 1 and 100")
+		return time.Time{}, time.Time{}, 0, false
+	}
+	return from, to, limit, true
+}
\ No newline at end of file
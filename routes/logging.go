@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Header used to correlate a request across services and log lines
+const requestIdHeader = "X-Request-ID"
+
+// maxRequestIdLength bounds client supplied request IDs
+const maxRequestIdLength = 128
+
+// requestLogger assigns a request ID, attaches a request-scoped logger to the request context and logs an access line
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		// Propagate the caller's request ID or assign a new one
+		requestId := c.GetHeader(requestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			requestId = uuid.NewString()
+		}
+		c.Header(requestIdHeader, requestId)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{zap.String("request_id", requestId), zap.String("route", route)}
+		if userId := c.Param("user_id"); userId != "" {
+			fields = append(fields, zap.String("user_id", userId))
+		} else if userId := c.GetHeader("X-User-Id"); userId != "" {
+			fields = append(fields, zap.String("user_id", userId))
+		}
+		// Tie log lines to the trace of the request
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+			fields = append(fields, zap.String("trace_id", spanContext.TraceID().String()))
+		}
+		c.Request = c.Request.WithContext(internal.WithLogFields(c.Request.Context(), fields...))
+
+		c.Next()
+
+		internal.LoggerFrom(c.Request.Context()).Info("Request completed",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		)
+	}
+}
+
+// setLogUser adds the user ID to the request-scoped logger, for handlers that read it from the body
+func setLogUser(c *gin.Context, userId string) {
+	c.Request = c.Request.WithContext(internal.WithLogFields(c.Request.Context(), zap.String("user_id", userId)))
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/ecommerce-store/utilities"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// inventoryStockDesc describes the stock level metric of watched products
+var inventoryStockDesc = prometheus.NewDesc(
+	"inventory_stock_level",
+	"Units in stock of watched products.",
+	[]string{"product_id"}, nil,
+)
+
+// inventoryCollector exports the stock of watched products at scrape time
+type inventoryCollector struct {
+	svc internal.ShoppingEngine
+}
+
+// Describe sends the descriptor of the stock metric
+func (i *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- inventoryStockDesc
+}
+
+// Collect sends the current stock of every watched product
+func (i *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	for productId, quantity := range i.svc.GetWatchedStock(context.Background()) {
+		ch <- prometheus.MustNewConstMetric(inventoryStockDesc, prometheus.GaugeValue, float64(quantity), productId)
+	}
+}
+
+// metricsMiddleware records the count and latency of every request by tenant, route and status
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Use the route pattern so that path parameters don't blow up the label cardinality
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		tenant := requestTenant(c)
+		utilities.Metrics.HTTPRequests.WithLabelValues(tenant, c.Request.Method, route, status).Inc()
+		utilities.Metrics.HTTPDuration.WithLabelValues(tenant, c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// registerInventoryMetrics exports the stock of the engine's watched products, labelled with the tenant like the other
+// business metrics so the stores of a process can be told apart
+func registerInventoryMetrics(svc internal.ShoppingEngine, tenant string) {
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"tenant": tenant}, utilities.Metrics.Registry)
+	if err := registerer.Register(&inventoryCollector{svc: svc}); err != nil {
+		internal.Logger.Sugar().Warnf("Inventory metrics not registered: %v", err)
+	}
+}
+
+func registerMetricsRoutes(router gin.IRoutes) {
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(utilities.Metrics.Registry, promhttp.HandlerOpts{})))
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ecommerce-store/config"
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// pathParam matches gin path parameters such as :user_id
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// createTestRouter registers every route of the engine on a new router
+func createTestRouter(svc internal.ShoppingEngine) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, svc, config.Default())
+	return router
+}
+
+// createConfiguredRouter registers every route of a new engine on a new router with the given configuration
+func createConfiguredRouter(cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, internal.NewEngine(cfg), cfg)
+	return router
+}
+
+// loadSpecOperations returns the operations of the embedded document as "METHOD /path"
+func loadSpecOperations(t *testing.T) map[string]bool {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(openAPISpec, &spec))
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	operations := make(map[string]bool)
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			operations[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return operations
+}
+
+// Test every registered route is documented and every documented route exists
+func TestOpenAPISpec_MatchesRoutes(t *testing.T) {
+	operations := loadSpecOperations(t)
+
+	registered := make(map[string]bool)
+	for _, route := range createTestRouter(internal.NewEngine(nil)).Routes() {
+		// OpenAPI writes path parameters as {user_id}
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		operation := route.Method + " " + path
+		registered[operation] = true
+		if operations[operation] {
+			continue
+		}
+		// Deprecated aliases are documented under their /v1 path
+		assert.True(t, operations[route.Method+" /v1"+path], "%s is not documented in openapi.json", operation)
+	}
+	for operation := range operations {
+		assert.True(t, registered[operation], "%s is documented in openapi.json but not registered", operation)
+	}
+}
+
+// Test the document and the docs page are served
+func TestOpenAPISpec_Served(t *testing.T) {
+	router := createTestRouter(internal.NewEngine(nil))
+
+	// Act
+	spec := httptest.NewRecorder()
+	router.ServeHTTP(spec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	docs := httptest.NewRecorder()
+	router.ServeHTTP(docs, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	// Assert
+	assert.Equal(t, 200, spec.Code)
+	assert.JSONEq(t, string(openAPISpec), spec.Body.String())
+	assert.Equal(t, 200, docs.Code)
+	assert.Contains(t, docs.Body.String(), "/openapi.json")
+}
+
+// Test unversioned aliases announce their deprecation and /v1 routes don't
+func TestLegacyRoutes_Deprecated(t *testing.T) {
+	router := createTestRouter(internal.NewEngine(nil))
+
+	// Act
+	legacy := httptest.NewRecorder()
+	router.ServeHTTP(legacy, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+	current := httptest.NewRecorder()
+	router.ServeHTTP(current, httptest.NewRequest(http.MethodGet, "/v1/admin/log-level", nil))
+
+	// Assert
+	assert.Equal(t, 200, legacy.Code)
+	assert.Equal(t, fmt.Sprintf("@%d", legacyDeprecatedAt.Unix()), legacy.Header().Get("Deprecation"))
+	assert.Equal(t, legacySunset.Format(http.TimeFormat), legacy.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/admin/log-level>; rel="successor-version"`, legacy.Header().Get("Link"))
+	assert.Equal(t, 200, current.Code)
+	assert.Empty(t, current.Header().Get("Deprecation"))
+	assert.Equal(t, legacy.Body.String(), current.Body.String())
+}
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ecommerce-store/config"
+	"github.com/gin-gonic/gin"
+)
+
+// How often buckets of clients that stopped sending requests are dropped
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds the requests a client may still make
+type tokenBucket struct {
+	tokens    float64   // Requests allowed right now
+	updatedAt time.Time // Time tokens was last refilled
+}
+
+// rateLimiter allows each client a sustained rate of requests with bursts up to a limit
+type rateLimiter struct {
+	rate      float64                 // Tokens added per second
+	burst     float64                 // Capacity of a bucket
+	buckets   map[string]*tokenBucket // Buckets by client IP
+	lastSweep time.Time               // Time idle buckets were last dropped
+	mutex     sync.Mutex              // Mutex to prevent race conditions on the buckets
+}
+
+// newRateLimiter creates and returns a rateLimiter object
+func newRateLimiter(limits config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		rate:    limits.RequestsPerSecond,
+		burst:   float64(limits.Burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the client's bucket, returning how long to wait for one if it is empty
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sweep(now)
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updatedAt: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*l.rate)
+	bucket.updatedAt = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled completely, the caller must hold the mutex
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.updatedAt) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// rateLimit responds with 429 to clients exceeding the configured rate, limiting is disabled when the rate is 0
+func rateLimit(limits config.RateLimit) gin.HandlerFunc {
+	if limits.RequestsPerSecond <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	limiter := newRateLimiter(limits)
+	return func(c *gin.Context) {
+		allowed, wait := limiter.allow(c.ClientIP(), time.Now())
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			errorResponse(c, 429, codeRateLimited, "Too many requests, retry later")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
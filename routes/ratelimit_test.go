@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ecommerce-store/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test clients are limited to the burst and then to the sustained rate
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter(config.RateLimit{RequestsPerSecond: 2, Burst: 3})
+	now := time.Now()
+
+	// Act
+	var allowed int
+	for i := 0; i < 5; i++ {
+		if ok, _ := limiter.allow("10.0.0.1", now); ok {
+			allowed++
+		}
+	}
+	_, wait := limiter.allow("10.0.0.1", now)
+	other, _ := limiter.allow("10.0.0.2", now)
+	refilled, _ := limiter.allow("10.0.0.1", now.Add(500*time.Millisecond))
+
+	// Assert
+	assert.Equal(t, 3, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	assert.True(t, other)
+	assert.True(t, refilled)
+}
+
+// Test idle clients are forgotten once their bucket is full again
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter := newRateLimiter(config.RateLimit{RequestsPerSecond: 1, Burst: 2})
+	now := time.Now()
+	limiter.allow("10.0.0.1", now)
+
+	// Act
+	limiter.allow("10.0.0.2", now.Add(rateLimitSweepInterval))
+
+	// Assert
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "10.0.0.2")
+}
+
+// Test requests over the limit are rejected with 429 and a Retry-After header
+func TestRateLimit_Middleware(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit = config.RateLimit{RequestsPerSecond: 1, Burst: 2}
+	router := createConfiguredRouter(cfg)
+
+	// Act
+	var codes []int
+	var last *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		last = httptest.NewRecorder()
+		router.ServeHTTP(last, httptest.NewRequest(http.MethodGet, "/v1/admin/analytics", nil))
+		codes = append(codes, last.Code)
+	}
+
+	// Assert
+	assert.Equal(t, []int{200, 200, 429}, codes)
+	assert.Equal(t, "1", last.Header().Get("Retry-After"))
+	assert.Contains(t, last.Body.String(), `"code":"rate_limited"`)
+}
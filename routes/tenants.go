@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ecommerce-store/config"
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/zap"
+)
+
+// tenantPathPrefix selects a tenant by path, /tenants/acme/v1/products is /v1/products of tenant acme
+const tenantPathPrefix = "/tenants/"
+
+// pathPrefixKey is the context key of the path prefix stripped before a tenant's routes saw the request
+type pathPrefixKey struct{}
+
+// tenantKey is the context key of the tenant a request was handed to
+type tenantKey struct{}
+
+// tenantStore is the router serving the requests of a tenant
+type tenantStore struct {
+	id     string
+	router *gin.Engine
+}
+
+// RegisterTenantRoutes serves every tenant of the config from its own engine. Each tenant gets its own router, bound to
+// its engine only, so a request can't reach the data of another tenant. Metrics and docs are shared by the process.
+func RegisterTenantRoutes(router *gin.Engine, cfg *config.Config, engines map[string]internal.ShoppingEngine) {
+	process := router.Group("", otelgin.Middleware(serviceName), metricsMiddleware(), requestLogger())
+	registerMetricsRoutes(process)
+	registerDocsRoutes(process)
+
+	// Clients are rate limited across all tenants, not once per tenant
+	limit := rateLimit(cfg.RateLimit)
+	stores := make(map[string]*tenantStore)
+	hosts := make(map[string]*tenantStore)
+	for _, tenant := range cfg.Tenants {
+		svc := engines[tenant.Id]
+		store := &tenantStore{id: tenant.Id, router: gin.New()}
+		// Redirects would drop the path prefix the tenant was selected by
+		store.router.RedirectTrailingSlash = false
+		store.router.Use(requestMiddleware(limit)...)
+		registerStoreRoutes(store.router, svc, cfg.ForTenant(tenant))
+		registerInventoryMetrics(svc, tenant.Id)
+
+		stores[tenant.Id] = store
+		for _, host := range tenant.Hosts {
+			hosts[strings.ToLower(host)] = store
+		}
+	}
+	router.NoRoute(resolveTenant(stores, hosts))
+}
+
+// resolveTenant hands the request to the tenant named by the path prefix, or else to the tenant serving the host
+func resolveTenant(stores map[string]*tenantStore, hosts map[string]*tenantStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := c.Request
+		prefix := ""
+		var store *tenantStore
+		if rest, found := strings.CutPrefix(request.URL.Path, tenantPathPrefix); found {
+			id, path, _ := strings.Cut(rest, "/")
+			store = stores[id]
+			prefix = tenantPathPrefix + id
+			request = request.Clone(request.Context())
+			request.URL.Path = "/" + path
+			request.URL.RawPath = ""
+		} else {
+			store = hosts[hostName(request.Host)]
+		}
+		if store == nil {
+			errorResponse(c, http.StatusNotFound, codeNotFound, "Unknown tenant")
+			c.Abort()
+			return
+		}
+
+		ctx := internal.WithLogFields(request.Context(), zap.String("tenant", store.id))
+		ctx = context.WithValue(ctx, pathPrefixKey{}, prefix)
+		ctx = context.WithValue(ctx, tenantKey{}, store.id)
+		store.router.ServeHTTP(c.Writer, request.WithContext(ctx))
+		c.Abort()
+	}
+}
+
+// pathPrefix returns the path prefix stripped before the routes saw the request
+func pathPrefix(c *gin.Context) string {
+	prefix, _ := c.Request.Context().Value(pathPrefixKey{}).(string)
+	return prefix
+}
+
+// requestTenant returns the tenant the request was handed to, empty for a single store and the routes of the process
+func requestTenant(c *gin.Context) string {
+	tenant, _ := c.Request.Context().Value(tenantKey{}).(string)
+	return tenant
+}
+
+// hostName returns the lowercase host of the Host header, without its port
+func hostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(host)
+}
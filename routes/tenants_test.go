@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecommerce-store/config"
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test each tenant is resolved by host or path prefix and only sees its own data
+func TestTenantRoutes_Isolated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Tenants = []config.Tenant{
+		{Id: "acme", Hosts: []string{"shop.acme.example"}, AdminToken: "acme-token"},
+		{Id: "globex", Hosts: []string{"globex.example"}, AdminToken: "globex-token"},
+	}
+	engines := map[string]internal.ShoppingEngine{}
+	for _, tenant := range cfg.Tenants {
+		engines[tenant.Id] = internal.NewEngine(cfg.ForTenant(tenant), internal.WithTenant(tenant.Id))
+	}
+	router := gin.New()
+	RegisterTenantRoutes(router, cfg, engines)
+
+	acme := engines["acme"]
+	seller, err := acme.RegisterUser(context.Background(), "Seller", "seller@example.com")
+	assert.NoError(t, err)
+	product, err := acme.RegisterProduct(context.Background(), "Product 1", "Description of product 1", 10, seller.Id, 10.0)
+	assert.NoError(t, err)
+	user, err := acme.RegisterUser(context.Background(), "Buyer", "buyer@example.com")
+	assert.NoError(t, err)
+	_, err = acme.AddToCart(context.Background(), user.Id, product.Id, 2)
+	assert.NoError(t, err)
+	_, err = acme.Checkout(context.Background(), user.Id, "")
+	assert.NoError(t, err)
+
+	request := func(host string, path string, token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	itemsSold := func(recorder *httptest.ResponseRecorder) float64 {
+		var body struct {
+			Data struct {
+				TotalItemsSold float64 `json:"total_items_sold"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		return body.Data.TotalItemsSold
+	}
+
+	// Act
+	byHost := request("Shop.Acme.example:8080", "/v1/products/"+product.Id, "")
+	byPrefix := request("localhost", "/tenants/acme/v1/products/"+product.Id, "")
+	otherTenant := request("globex.example", "/v1/products/"+product.Id, "")
+	legacy := request("localhost", "/tenants/acme/products/"+product.Id, "")
+	acmeAnalytics := request("shop.acme.example", "/v1/admin/analytics", "acme-token")
+	globexAnalytics := request("localhost", "/tenants/globex/v1/admin/analytics", "globex-token")
+	foreignToken := request("globex.example", "/v1/admin/analytics", "acme-token")
+	unknownHost := request("unknown.example", "/v1/products/"+product.Id, "")
+	unknownPrefix := request("localhost", "/tenants/initech/v1/products/"+product.Id, "")
+	metrics := request("localhost", "/metrics", "")
+
+	// Assert
+	assert.Equal(t, 200, byHost.Code)
+	assert.Equal(t, 200, byPrefix.Code)
+	assert.Equal(t, 404, otherTenant.Code)
+	assert.Equal(t, 200, legacy.Code)
+	assert.Equal(t, `</tenants/acme/v1/products/`+product.Id+`>; rel="successor-version"`, legacy.Header().Get("Link"))
+	assert.Equal(t, 200, acmeAnalytics.Code)
+	assert.Equal(t, 2.0, itemsSold(acmeAnalytics))
+	assert.Equal(t, 200, globexAnalytics.Code)
+	assert.Equal(t, 0.0, itemsSold(globexAnalytics))
+	assert.Equal(t, 401, foreignToken.Code)
+	assert.Equal(t, 404, unknownHost.Code)
+	assert.Contains(t, unknownHost.Body.String(), "Unknown tenant")
+	assert.Equal(t, 404, unknownPrefix.Code)
+	assert.Equal(t, 200, metrics.Code)
+	assert.Contains(t, metrics.Body.String(), `orders_placed_total{tenant="acme"}`)
+	assert.Contains(t, metrics.Body.String(), `http_requests_total{method="GET",route="/v1/products/:product_id",status="200",tenant="acme"}`)
+	assert.Contains(t, metrics.Body.String(), `http_requests_total{method="GET",route="/v1/admin/analytics",status="200",tenant="globex"}`)
+}
@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecommerce-store/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test routes of a user's own data reject callers who don't claim to be that user
+func TestUserRoutes_RequireCaller(t *testing.T) {
+	svc := internal.NewEngine(nil)
+	user, err := svc.RegisterUser(context.Background(), "Buyer", "buyer@example.com")
+	assert.NoError(t, err)
+	router := createTestRouter(svc)
+	request := func(method string, path string, body string, callerId string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/users/"+user.Id+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if callerId != "" {
+			req.Header.Set("X-User-Id", callerId)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	for _, route := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/wallet", ""},
+		{http.MethodPost, "/wallet/redeem", `{"code":"ABC"}`},
+		{http.MethodPost, "/gift-cards", `{"amount":25}`},
+		{http.MethodGet, "/points", ""},
+		{http.MethodPost, "/orders/order-1/cancel", ""},
+	} {
+		// Act
+		missing := request(route.method, route.path, route.body, "")
+		mismatched := request(route.method, route.path, route.body, "someone-else")
+
+		// Assert
+		assert.Equal(t, 401, missing.Code, route.path)
+		assert.Equal(t, 403, mismatched.Code, route.path)
+	}
+
+	// Act
+	own := request(http.MethodGet, "/wallet", "", user.Id)
+
+	// Assert
+	assert.Equal(t, 200, own.Code)
+}
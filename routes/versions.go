@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ecommerce-store/config"
+	"github.com/ecommerce-store/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// Unversioned aliases of /v1 were deprecated on this date and are removed after the sunset
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// registerV1Routes registers version 1 of the API on the group.
+// A new version gets its own register function and handlers over the same engine, so response shapes can change without breaking v1 clients.
+func registerV1Routes(rg *gin.RouterGroup, svc internal.ShoppingEngine, cfg *config.Config) {
+	admin := rg.Group("/admin", requireAdminToken(cfg.Auth.AdminToken))
+	registerAdminRoutes(admin, svc)
+
+	auth := rg.Group("/auth")
+	registerAuthRoutes(auth, svc)
+
+	users := rg.Group("/users")
+	registerUserRoutes(users, svc)
+
+	orders := rg.Group("/orders")
+	registerOrderRoutes(orders, svc)
+
+	products := rg.Group("/products")
+	registerProductRoutes(products, svc)
+
+	// Sellers have no credentials of their own yet, their routes take the admin token like the admin routes
+	sellers := rg.Group("/sellers", requireAdminToken(cfg.Auth.AdminToken))
+	registerSellerRoutes(sellers, svc)
+
+	payments := rg.Group("/payments")
+	registerPaymentRoutes(payments, svc)
+}
+
+// deprecated announces the deprecation and sunset of a route (RFC 9745 and RFC 8594) and links to the same path under the successor prefix
+func deprecated(deprecatedAt time.Time, sunset time.Time, successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", fmt.Sprintf("@%d", deprecatedAt.Unix()))
+		c.Header("Sunset", sunset.Format(http.TimeFormat))
+		c.Header("Link", fmt.Sprintf(`<%s%s%s>; rel="successor-version"`, pathPrefix(c), successor, c.Request.URL.Path))
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	shoppingv1 "github.com/ecommerce-store/proto/shopping/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// orderStatuses maps engine order statuses onto their protobuf values
+var orderStatuses = map[string]shoppingv1.OrderStatus{
+	string(internal.OrderStatusAuthorized): shoppingv1.OrderStatus_ORDER_STATUS_AUTHORIZED,
+	string(internal.OrderStatusPaid):       shoppingv1.OrderStatus_ORDER_STATUS_PAID,
+	string(internal.OrderStatusFailed):     shoppingv1.OrderStatus_ORDER_STATUS_FAILED,
+	string(internal.OrderStatusRefunded):   shoppingv1.OrderStatus_ORDER_STATUS_REFUNDED,
+	string(internal.OrderStatusDisputed):   shoppingv1.OrderStatus_ORDER_STATUS_DISPUTED,
+	string(internal.OrderStatusCancelled):  shoppingv1.OrderStatus_ORDER_STATUS_CANCELLED,
+}
+
+// toOrderStatus converts an engine order status, unknown statuses are unspecified
+func toOrderStatus[S ~string](status S) shoppingv1.OrderStatus {
+	return orderStatuses[string(status)]
+}
+
+// toTimestamp converts a time, leaving zero times unset
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// toCart converts a cart of product quantities
+func toCart(userId string, cart map[string]int) *shoppingv1.Cart {
+	items := make(map[string]int32, len(cart))
+	for productId, quantity := range cart {
+		items[productId] = int32(quantity)
+	}
+	return &shoppingv1.Cart{UserId: userId, Items: items}
+}
+
+// toUser converts a user, leaving out the cart, wallet and points
+func toUser(u *internal.User) *shoppingv1.User {
+	return &shoppingv1.User{Id: u.Id, Name: u.Name, Email: u.Email}
+}
+
+// toProduct converts a product
+func toProduct(p *internal.Product) *shoppingv1.Product {
+	return &shoppingv1.Product{
+		Id:          p.Id,
+		Name:        p.Name,
+		Description: p.Description,
+		Quantity:    int32(p.Quantity),
+		Price:       p.Price,
+		SellerId:    p.SellerId,
+		Category:    p.Category,
+	}
+}
+
+// toOrder converts an order with its line items
+func toOrder(o *internal.Order) *shoppingv1.Order {
+	items := make([]*shoppingv1.LineItem, 0, len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, &shoppingv1.LineItem{
+			ProductId: item.ProductId,
+			Name:      item.Name,
+			SellerId:  item.SellerId,
+			Category:  item.Category,
+			UnitPrice: item.UnitPrice,
+			Quantity:  int32(item.Quantity),
+			LineTotal: item.LineTotal,
+			Discount:  item.Discount,
+		})
+	}
+	return &shoppingv1.Order{
+		Id:              o.Id,
+		UserId:          o.UserId,
+		Items:           items,
+		Amount:          o.CartTotal,
+		Discount:        o.Discount,
+		DiscountCoupon:  o.DiscountCoupon,
+		AmountToPay:     o.AmountToPay,
+		PaidFromWallet:  o.PaidFromWallet,
+		PaidByProvider:  o.PaidByProvider,
+		PointsRedeemed:  int32(o.PointsRedeemed),
+		PointsDiscount:  o.PointsDiscount,
+		PointsEarned:    int32(o.PointsEarned),
+		PaymentId:       o.PaymentId,
+		Status:          toOrderStatus(o.Status),
+		CreatedAt:       toTimestamp(o.CreatedAt),
+		StatusUpdatedAt: toTimestamp(o.StatusUpdatedAt),
+	}
+}
+
+// toStatusUpdate converts an order status update
+func toStatusUpdate(update internal.OrderStatusUpdate) *shoppingv1.OrderStatusUpdate {
+	return &shoppingv1.OrderStatusUpdate{
+		OrderId:   update.OrderId,
+		Status:    toOrderStatus(update.Status),
+		UpdatedAt: toTimestamp(update.UpdatedAt),
+	}
+}
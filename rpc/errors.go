@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ecommerce-store/internal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorMapping maps an engine error to a gRPC status code
+type errorMapping struct {
+	err  error      // Error matched with errors.Is
+	code codes.Code // Code of the returned status
+}
+
+// errorMappings is checked in order, so specific errors must come before the kind they wrap
+var errorMappings = []errorMapping{
+	{internal.ErrIdempotencyKeyReused, codes.FailedPrecondition},
+	{internal.ErrIdempotencyKeyInProgress, codes.Aborted},
+	{internal.ErrPaymentDeclined, codes.FailedPrecondition},
+	{internal.ErrNotFound, codes.NotFound},
+	{internal.ErrConflict, codes.FailedPrecondition},
+	{internal.ErrInsufficientStock, codes.FailedPrecondition},
+	{internal.ErrCouponInvalid, codes.InvalidArgument},
+	{internal.ErrCouponExpired, codes.FailedPrecondition},
+	{internal.ErrValidation, codes.InvalidArgument},
+}
+
+// toStatus converts an engine error into a gRPC status error
+func toStatus(ctx context.Context, err error) error {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return status.Error(mapping.code, err.Error())
+		}
+	}
+	internal.LoggerFrom(ctx).Sugar().Errorf("RPC failed: %v", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+// invalidArgument returns an InvalidArgument status for a malformed request
+func invalidArgument(message string) error {
+	return status.Error(codes.InvalidArgument, message)
+}
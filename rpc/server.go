@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecommerce-store/internal"
+	shoppingv1 "github.com/ecommerce-store/proto/shopping/v1"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys mirroring the X-Request-ID and X-User-Id HTTP headers
+const (
+	requestIdKey = "x-request-id"
+	userIdKey    = "x-user-id"
+)
+
+// maxRequestIdLength bounds client supplied request IDs
+const maxRequestIdLength = 128
+
+// NewServer returns a gRPC server exposing the users, products, carts, checkout and orders of the engine
+func NewServer(svc internal.ShoppingEngine, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryLogger()),
+		grpc.ChainStreamInterceptor(streamLogger()),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	shoppingv1.RegisterUserServiceServer(server, &userServer{svc: svc})
+	shoppingv1.RegisterProductServiceServer(server, &productServer{svc: svc})
+	shoppingv1.RegisterCartServiceServer(server, &cartServer{svc: svc})
+	shoppingv1.RegisterCheckoutServiceServer(server, &checkoutServer{svc: svc})
+	shoppingv1.RegisterOrderServiceServer(server, &orderServer{svc: svc})
+	return server
+}
+
+// requestContext attaches a request-scoped logger and the calling user to the context, like the HTTP middleware does
+func requestContext(ctx context.Context, method string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Propagate the caller's request ID or assign a new one
+	requestId := firstValue(md, requestIdKey)
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		requestId = uuid.NewString()
+	}
+	fields := []zap.Field{zap.String("request_id", requestId), zap.String("rpc", method)}
+	if userId := firstValue(md, userIdKey); userId != "" {
+		fields = append(fields, zap.String("user_id", userId))
+		ctx = internal.WithClaimedActor(ctx, userId)
+	}
+	return internal.WithLogFields(ctx, fields...)
+}
+
+// firstValue returns the first value of the metadata key, or an empty string
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// logCompleted logs the access line of a finished call
+func logCompleted(ctx context.Context, start time.Time, err error) {
+	internal.LoggerFrom(ctx).Info("RPC completed",
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("latency", time.Since(start)),
+	)
+}
+
+// unaryLogger logs an access line for every unary call
+func unaryLogger() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = requestContext(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		logCompleted(ctx, start, err)
+		return resp, err
+	}
+}
+
+// streamLogger logs an access line for every streaming call once the stream ends
+func streamLogger() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := requestContext(stream.Context(), info.FullMethod)
+		err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+		logCompleted(ctx, start, err)
+		return err
+	}
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
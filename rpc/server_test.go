@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ecommerce-store/internal"
+	shoppingv1 "github.com/ecommerce-store/proto/shopping/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Helper function to serve the engine over an in-process listener and dial it
+func createTestClient(t *testing.T) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(internal.NewEngine(nil))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Helper function to make calls as the user
+func asUser(userId string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), userIdKey, userId)
+}
+
+// Helper function to register a user with a product in the cart
+func createUserWithCart(t *testing.T, conn *grpc.ClientConn) (*shoppingv1.User, *shoppingv1.Product) {
+	ctx := context.Background()
+	users := shoppingv1.NewUserServiceClient(conn)
+	seller, err := users.RegisterUser(ctx, &shoppingv1.RegisterUserRequest{Name: "Seller", Email: uuid.NewString() + "@example.com"})
+	assert.NoError(t, err)
+	product, err := shoppingv1.NewProductServiceClient(conn).RegisterProduct(ctx, &shoppingv1.RegisterProductRequest{
+		Name: "Product 1", Description: "Description of product 1", Quantity: 10, SellerId: seller.Id, Price: 20.0,
+	})
+	assert.NoError(t, err)
+
+	user, err := users.RegisterUser(ctx, &shoppingv1.RegisterUserRequest{Name: "Buyer", Email: uuid.NewString() + "@example.com"})
+	assert.NoError(t, err)
+	_, err = shoppingv1.NewCartServiceClient(conn).AddToCart(ctx, &shoppingv1.AddToCartRequest{UserId: user.Id, ProductId: product.Id, Quantity: 2})
+	assert.NoError(t, err)
+	return user, product
+}
+
+// Test a cart can be checked out and the order looked up
+func TestCheckout(t *testing.T) {
+	conn := createTestClient(t)
+	user, product := createUserWithCart(t, conn)
+
+	// Act
+	response, err := shoppingv1.NewCheckoutServiceClient(conn).Checkout(context.Background(), &shoppingv1.CheckoutRequest{UserId: user.Id})
+
+	// Assert
+	assert.NoError(t, err)
+	order := response.Order
+	assert.Equal(t, user.Id, order.UserId)
+	assert.Equal(t, shoppingv1.OrderStatus_ORDER_STATUS_PAID, order.Status)
+	assert.Equal(t, 40.0, order.Amount)
+	assert.Len(t, order.Items, 1)
+	assert.Equal(t, product.Id, order.Items[0].ProductId)
+	assert.Equal(t, int32(2), order.Items[0].Quantity)
+	assert.NotNil(t, order.CreatedAt)
+
+	found, err := shoppingv1.NewOrderServiceClient(conn).GetOrder(asUser(user.Id), &shoppingv1.GetOrderRequest{UserId: user.Id, OrderId: order.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, order.Id, found.Id)
+
+	cart, err := shoppingv1.NewCartServiceClient(conn).GetCart(context.Background(), &shoppingv1.GetCartRequest{UserId: user.Id})
+	assert.NoError(t, err)
+	assert.Empty(t, cart.Items)
+}
+
+// Test retrying a checkout with the same idempotency key replays the order
+func TestCheckout_IdempotencyKey(t *testing.T) {
+	conn := createTestClient(t)
+	user, _ := createUserWithCart(t, conn)
+	checkout := shoppingv1.NewCheckoutServiceClient(conn)
+	request := &shoppingv1.CheckoutRequest{UserId: user.Id, IdempotencyKey: uuid.NewString()}
+
+	// Act
+	first, err := checkout.Checkout(context.Background(), request)
+	assert.NoError(t, err)
+	second, err := checkout.Checkout(context.Background(), request)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, first.Replayed)
+	assert.True(t, second.Replayed)
+	assert.Equal(t, first.Order.Id, second.Order.Id)
+}
+
+// Test engine errors are returned with matching status codes
+func TestErrors_StatusCodes(t *testing.T) {
+	conn := createTestClient(t)
+	user, product := createUserWithCart(t, conn)
+
+	// Act
+	_, notFound := shoppingv1.NewProductServiceClient(conn).GetProduct(context.Background(), &shoppingv1.GetProductRequest{ProductId: "missing"})
+	_, invalid := shoppingv1.NewUserServiceClient(conn).RegisterUser(context.Background(), &shoppingv1.RegisterUserRequest{Name: "Ana", Email: "not-an-email"})
+	_, err := shoppingv1.NewCartServiceClient(conn).AddToCart(context.Background(), &shoppingv1.AddToCartRequest{UserId: user.Id, ProductId: product.Id, Quantity: 100})
+	assert.NoError(t, err)
+	_, outOfStock := shoppingv1.NewCheckoutServiceClient(conn).Checkout(context.Background(), &shoppingv1.CheckoutRequest{UserId: user.Id})
+
+	// Assert
+	assert.Equal(t, codes.NotFound, status.Code(notFound))
+	assert.Equal(t, codes.InvalidArgument, status.Code(invalid))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(outOfStock))
+}
+
+// Test order status updates are streamed until the order is cancelled
+func TestWatchOrderStatus(t *testing.T) {
+	conn := createTestClient(t)
+	user, _ := createUserWithCart(t, conn)
+	response, err := shoppingv1.NewCheckoutServiceClient(conn).Checkout(context.Background(), &shoppingv1.CheckoutRequest{UserId: user.Id})
+	assert.NoError(t, err)
+	orders := shoppingv1.NewOrderServiceClient(conn)
+
+	// Act
+	stream, err := orders.WatchOrderStatus(asUser(user.Id), &shoppingv1.WatchOrderStatusRequest{UserId: user.Id, OrderId: response.Order.Id})
+	assert.NoError(t, err)
+	current, err := stream.Recv()
+	assert.NoError(t, err)
+	_, err = orders.CancelOrder(asUser(user.Id), &shoppingv1.CancelOrderRequest{UserId: user.Id, OrderId: response.Order.Id})
+	assert.NoError(t, err)
+	cancelled, err := stream.Recv()
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+
+	// Assert
+	assert.Equal(t, shoppingv1.OrderStatus_ORDER_STATUS_PAID, current.Status)
+	assert.Equal(t, shoppingv1.OrderStatus_ORDER_STATUS_CANCELLED, cancelled.Status)
+	assert.Equal(t, response.Order.Id, cancelled.OrderId)
+	assert.Equal(t, io.EOF, err)
+}
+
+// Test users can't watch orders of other users
+func TestWatchOrderStatus_OtherUser(t *testing.T) {
+	conn := createTestClient(t)
+	user, _ := createUserWithCart(t, conn)
+	other, _ := createUserWithCart(t, conn)
+	response, err := shoppingv1.NewCheckoutServiceClient(conn).Checkout(context.Background(), &shoppingv1.CheckoutRequest{UserId: user.Id})
+	assert.NoError(t, err)
+
+	// Act
+	stream, err := shoppingv1.NewOrderServiceClient(conn).WatchOrderStatus(asUser(other.Id), &shoppingv1.WatchOrderStatusRequest{UserId: other.Id, OrderId: response.Order.Id})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+
+	// Assert
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// Test order calls are rejected unless the caller claims to be the user
+func TestOrders_RequireCaller(t *testing.T) {
+	conn := createTestClient(t)
+	user, _ := createUserWithCart(t, conn)
+	response, err := shoppingv1.NewCheckoutServiceClient(conn).Checkout(context.Background(), &shoppingv1.CheckoutRequest{UserId: user.Id})
+	assert.NoError(t, err)
+	orders := shoppingv1.NewOrderServiceClient(conn)
+	request := &shoppingv1.CancelOrderRequest{UserId: user.Id, OrderId: response.Order.Id}
+
+	// Act
+	_, missing := orders.CancelOrder(context.Background(), request)
+	_, mismatched := orders.CancelOrder(asUser("someone-else"), request)
+	_, lookup := orders.GetOrder(asUser("someone-else"), &shoppingv1.GetOrderRequest{UserId: user.Id, OrderId: response.Order.Id})
+	_, cancelErr := orders.CancelOrder(asUser(user.Id), request)
+	_, again := orders.CancelOrder(asUser(user.Id), request)
+
+	// Assert
+	assert.Equal(t, codes.Unauthenticated, status.Code(missing))
+	assert.Equal(t, codes.PermissionDenied, status.Code(mismatched))
+	assert.Equal(t, codes.PermissionDenied, status.Code(lookup))
+	assert.NoError(t, cancelErr)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(again))
+}
@@ -0,0 +1,209 @@
+package rpc
+
+import (
+	"context"
+	"net/mail"
+
+	"github.com/ecommerce-store/internal"
+	shoppingv1 "github.com/ecommerce-store/proto/shopping/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requireCaller returns an error unless the caller claims to be the given user in the x-user-id metadata.
+// Like the X-User-Id header it is not authenticated, it only keeps honest clients to their own data.
+func requireCaller(ctx context.Context, userId string, forbidden string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	callerId := firstValue(md, userIdKey)
+	if callerId == "" {
+		return status.Error(codes.Unauthenticated, "x-user-id metadata is required")
+	}
+	if callerId != userId {
+		return status.Error(codes.PermissionDenied, forbidden)
+	}
+	return nil
+}
+
+// userServer implements shoppingv1.UserServiceServer
+type userServer struct {
+	shoppingv1.UnimplementedUserServiceServer
+	svc internal.ShoppingEngine
+}
+
+func (s *userServer) RegisterUser(ctx context.Context, req *shoppingv1.RegisterUserRequest) (*shoppingv1.User, error) {
+	if req.GetName() == "" || req.GetEmail() == "" {
+		return nil, invalidArgument("Name and email are required")
+	}
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, invalidArgument("Invalid email format")
+	}
+
+	user, err := s.svc.RegisterUser(ctx, req.GetName(), req.GetEmail())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toUser(user), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *shoppingv1.GetUserRequest) (*shoppingv1.User, error) {
+	if req.GetUserId() == "" {
+		return nil, invalidArgument("User ID cannot be empty")
+	}
+
+	user, err := s.svc.GetUser(ctx, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toUser(user), nil
+}
+
+// productServer implements shoppingv1.ProductServiceServer
+type productServer struct {
+	shoppingv1.UnimplementedProductServiceServer
+	svc internal.ShoppingEngine
+}
+
+func (s *productServer) RegisterProduct(ctx context.Context, req *shoppingv1.RegisterProductRequest) (*shoppingv1.Product, error) {
+	if req.GetSellerId() == "" || req.GetName() == "" || req.GetPrice() < 0.0 || req.GetQuantity() < 0 {
+		return nil, invalidArgument("Invalid request data")
+	}
+	// Sellers register their own products unless an actor was given
+	if md, _ := metadata.FromIncomingContext(ctx); firstValue(md, userIdKey) == "" {
+		ctx = internal.WithClaimedActor(ctx, req.GetSellerId())
+	}
+
+	product, err := s.svc.RegisterProduct(ctx, req.GetName(), req.GetDescription(), int(req.GetQuantity()), req.GetSellerId(), req.GetPrice())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	// Categorize the product if a category was given
+	if req.GetCategory() != "" {
+		product, err = s.svc.SetProductCategory(ctx, product.Id, req.GetCategory())
+		if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+	}
+	return toProduct(product), nil
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *shoppingv1.GetProductRequest) (*shoppingv1.Product, error) {
+	if req.GetProductId() == "" {
+		return nil, invalidArgument("Product ID cannot be empty")
+	}
+
+	product, err := s.svc.GetProduct(ctx, req.GetProductId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProduct(product), nil
+}
+
+// cartServer implements shoppingv1.CartServiceServer
+type cartServer struct {
+	shoppingv1.UnimplementedCartServiceServer
+	svc internal.ShoppingEngine
+}
+
+func (s *cartServer) AddToCart(ctx context.Context, req *shoppingv1.AddToCartRequest) (*shoppingv1.Cart, error) {
+	if req.GetUserId() == "" {
+		return nil, invalidArgument("User ID cannot be empty")
+	}
+
+	cart, err := s.svc.AddToCart(ctx, req.GetUserId(), req.GetProductId(), int(req.GetQuantity()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toCart(req.GetUserId(), cart), nil
+}
+
+func (s *cartServer) GetCart(ctx context.Context, req *shoppingv1.GetCartRequest) (*shoppingv1.Cart, error) {
+	if req.GetUserId() == "" {
+		return nil, invalidArgument("User ID cannot be empty")
+	}
+
+	cart, err := s.svc.GetCart(ctx, req.GetUserId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toCart(req.GetUserId(), cart), nil
+}
+
+// checkoutServer implements shoppingv1.CheckoutServiceServer
+type checkoutServer struct {
+	shoppingv1.UnimplementedCheckoutServiceServer
+	svc internal.ShoppingEngine
+}
+
+func (s *checkoutServer) Checkout(ctx context.Context, req *shoppingv1.CheckoutRequest) (*shoppingv1.CheckoutResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, invalidArgument("User ID cannot be empty")
+	}
+	options := internal.CheckoutOptions{
+		CouponCode:   req.GetCouponCode(),
+		WalletAmount: req.GetWalletAmount(),
+		RedeemPoints: int(req.GetRedeemPoints()),
+	}
+
+	// Place the order, at most once per idempotency key if one is supplied
+	var order *internal.Order
+	var replayed bool
+	var err error
+	if key := req.GetIdempotencyKey(); key != "" {
+		order, replayed, err = s.svc.CheckoutWithIdempotencyKey(ctx, key, req.GetUserId(), options)
+	} else {
+		order, err = s.svc.CheckoutWithOptions(ctx, req.GetUserId(), options)
+	}
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &shoppingv1.CheckoutResponse{Order: toOrder(order), Replayed: replayed}, nil
+}
+
+// orderServer implements shoppingv1.OrderServiceServer
+type orderServer struct {
+	shoppingv1.UnimplementedOrderServiceServer
+	svc internal.ShoppingEngine
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *shoppingv1.GetOrderRequest) (*shoppingv1.Order, error) {
+	if err := requireCaller(ctx, req.GetUserId(), "Users can only view their own orders"); err != nil {
+		return nil, err
+	}
+	order, err := s.svc.GetOrder(ctx, req.GetUserId(), req.GetOrderId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toOrder(order), nil
+}
+
+func (s *orderServer) CancelOrder(ctx context.Context, req *shoppingv1.CancelOrderRequest) (*shoppingv1.Order, error) {
+	if err := requireCaller(ctx, req.GetUserId(), "Users can only cancel their own orders"); err != nil {
+		return nil, err
+	}
+	order, err := s.svc.CancelOrder(ctx, req.GetUserId(), req.GetOrderId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toOrder(order), nil
+}
+
+func (s *orderServer) WatchOrderStatus(req *shoppingv1.WatchOrderStatusRequest, stream shoppingv1.OrderService_WatchOrderStatusServer) error {
+	ctx := stream.Context()
+	if err := requireCaller(ctx, req.GetUserId(), "Users can only watch their own orders"); err != nil {
+		return err
+	}
+	updates, err := s.svc.WatchOrderStatus(ctx, req.GetUserId(), req.GetOrderId())
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+
+	// The engine closes the channel once the order is final or the client goes away
+	for update := range updates {
+		if err := stream.Send(toStatusUpdate(update)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
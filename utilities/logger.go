@@ -2,72 +2,265 @@ package utilities
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/mattn/go-colorable"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// zapSession is a wrapper for zap.Logger to provide a single instance of the logger.
-type zapSession struct {
-	zap     	*zap.Logger // zap logger instance
-	once        sync.Once   // once is used to make sure that the session is created only once
-	environment string      // environment is the environment the logger is running in
-	serviceName string      // serviceName is the name of the service the logger is running in
+// Environment variables read by LoggerConfigFromEnv
+const (
+	AppEnvironmentEnv      = "APP_ENV"                 // Environment the service runs in (e.g. dev, production)
+	LogLevelEnv            = "LOG_LEVEL"               // Minimum level (debug, info, warn, error)
+	LogEncodingEnv         = "LOG_ENCODING"            // json or console
+	LogOutputEnv           = "LOG_OUTPUT"              // stdout, stderr or a file path
+	LogMaxSizeEnv          = "LOG_MAX_SIZE_MB"         // Size in megabytes at which the log file is rotated
+	LogMaxAgeEnv           = "LOG_MAX_AGE_DAYS"        // Days rotated log files are kept
+	LogMaxBackupsEnv       = "LOG_MAX_BACKUPS"         // Number of rotated log files kept
+	LogSamplingInitialEnv  = "LOG_SAMPLING_INITIAL"    // Entries with the same message logged per second before sampling
+	LogSamplingAfterEnv    = "LOG_SAMPLING_THEREAFTER" // Every Nth entry logged after that, 0 disables sampling
+)
+
+// Encodings accepted by LoggerConfig
+const (
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+)
+
+// LoggerConfig describes how log entries are encoded and where they are written.
+type LoggerConfig struct {
+	Environment        string  // Environment the service runs in
+	ServiceName        string  // Name of the service added to every entry
+	Level              string  // Minimum level, derived from the environment when empty
+	Encoding           string  // json or console
+	Output             string  // stdout, stderr or a file path
+	MaxSizeMB          int     // Size at which the log file is rotated
+	MaxAgeDays         int     // Days rotated log files are kept, 0 keeps them forever
+	MaxBackups         int     // Rotated log files kept, 0 keeps all of them
+	Compress           bool    // Compress rotated log files
+	SamplingInitial    int     // Entries with the same message logged per second before sampling
+	SamplingThereafter int     // Every Nth entry logged after that, 0 disables sampling
 }
 
-// Session returns a zap logger instance.
-func (ctx *zapSession) Session(environment string, serviceName string) *zap.Logger {
+// LoggerConfigFromEnv reads the logger configuration from the environment, for loggers created before the service config is loaded.
+func LoggerConfigFromEnv(serviceName string) LoggerConfig {
+	config := LoggerConfig{
+		Environment:        os.Getenv(AppEnvironmentEnv),
+		ServiceName:        serviceName,
+		Level:              os.Getenv(LogLevelEnv),
+		Encoding:           os.Getenv(LogEncodingEnv),
+		Output:             os.Getenv(LogOutputEnv),
+		MaxSizeMB:          envInt(LogMaxSizeEnv, 100),
+		MaxAgeDays:         envInt(LogMaxAgeEnv, 7),
+		MaxBackups:         envInt(LogMaxBackupsEnv, 5),
+		Compress:           true,
+		SamplingInitial:    envInt(LogSamplingInitialEnv, 100),
+		SamplingThereafter: envInt(LogSamplingAfterEnv, 100),
+	}
+	return config.WithDefaults()
+}
 
-	ctx.environment = environment
-	ctx.serviceName = serviceName
+// BootstrapLoggerConfig is the config of the logger used until the service config is loaded. It reads the level and
+// encoding from the environment but always writes to stderr, so only the configured logger opens log files.
+func BootstrapLoggerConfig(serviceName string) LoggerConfig {
+	config := LoggerConfigFromEnv(serviceName)
+	config.Output = "stderr"
+	return config
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Printf("failed to create logger: %v\n", r)
+// WithDefaults fills in the environment, encoding and output when they are not set.
+func (c LoggerConfig) WithDefaults() LoggerConfig {
+	if c.Environment == "" {
+		c.Environment = "dev"
+	}
+	if c.Encoding == "" {
+		// Human readable output in development, machine readable elsewhere
+		c.Encoding = EncodingJSON
+		if c.isDev() {
+			c.Encoding = EncodingConsole
 		}
-		ctx.close()
-	}()
+	}
+	if c.Output == "" {
+		c.Output = "stdout"
+	}
+	return c
+}
 
+// envInt reads an integer from the environment, falling back to def if unset or invalid
+func envInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// isDev reports whether the config is for a development environment
+func (c LoggerConfig) isDev() bool {
+	return c.Environment == "Dev" || c.Environment == "dev"
+}
+
+// zapSession is a wrapper for zap.Logger to provide a single instance of the logger.
+type zapSession struct {
+	zap     	*zap.Logger     // zap logger instance
+	level       zap.AtomicLevel // level read by the current logger, can be changed at runtime
+	once        sync.Once       // once is used to make sure that the session is created only once
+	config      LoggerConfig    // config the logger was created from
+	mutex       sync.RWMutex    // mutex guarding the logger, its level and config when the logger is replaced
+}
+
+// Session returns a zap logger instance.
+func (ctx *zapSession) Session(config LoggerConfig) *zap.Logger {
 	ctx.once.Do(func() {
-		config, _, err := ctx.loadConfiguration()
+		start := time.Now()
+		core, level, err := ctx.loadConfiguration(config)
 		if err != nil {
-			fmt.Printf("failed to create logger: %v\n", err)
-			return
+			fmt.Printf("failed to create logger, using defaults: %v\n", err)
+			config = LoggerConfig{Environment: config.Environment, ServiceName: config.ServiceName, Encoding: EncodingConsole, Output: "stdout"}
+			core, level, _ = ctx.loadConfiguration(config)
 		}
-		ctx.zap = zap.New(zapcore.NewCore(
-			zapcore.NewConsoleEncoder(*config),
-			zapcore.AddSync(colorable.NewColorableStdout()),
-			ctx.logLeveler(),
-		))
-		println("")
+		logger := ctx.replace(config, core, level)
+
 		// Log the time it took to initialize the logger.
-		ctx.zap.Sugar().Debugf("Logger Initialized ==< %v ms >== | Service ==< %v >== ", zap.Duration("took", time.Since(time.Now())).Integer, ctx.serviceName)
+		logger.Sugar().Debugf("Logger Initialized ==< %v ms >== | Service ==< %v >== ", time.Since(start).Milliseconds(), config.ServiceName)
 	})
+
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+	return ctx.zap
+}
+
+// Configure replaces the logger with one built from the config, unlike Session it fails instead of falling back to defaults.
+func (ctx *zapSession) Configure(config LoggerConfig) (*zap.Logger, error) {
+	// The current logger and its level stay in use if the config is invalid
+	core, level, err := ctx.loadConfiguration(config)
+	if err != nil {
+		return nil, err
+	}
+	// A later call to Session keeps this logger
+	ctx.once.Do(func() {})
+	return ctx.replace(config, core, level), nil
+}
+
+// replace makes a logger writing to the core the current one, along with the level the core reads.
+func (ctx *zapSession) replace(config LoggerConfig, core zapcore.Core, level zap.AtomicLevel) *zap.Logger {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	ctx.config = config
+	ctx.level = level
+	ctx.zap = zap.New(core).With(zap.String("service", config.ServiceName), zap.String("environment", config.Environment))
 	return ctx.zap
 }
 
-// config returns the encoder config and atomic level for the logger.
-func (ctx *zapSession) loadConfiguration() (*zapcore.EncoderConfig, *zap.AtomicLevel, error) {
-	atom := zap.NewAtomicLevel()
-	config := zap.NewDevelopmentEncoderConfig()
-	config.TimeKey = "timestamp"
-	config.FunctionKey = "func"
-	config.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339Nano)
-	config.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	return &config, &atom, nil
+// loadConfiguration builds the zap core described by the config, along with the level it reads.
+func (ctx *zapSession) loadConfiguration(config LoggerConfig) (zapcore.Core, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevelAt(ctx.logLeveler(config))
+	if config.Level != "" {
+		parsed, err := zapcore.ParseLevel(config.Level)
+		if err != nil {
+			return nil, level, err
+		}
+		level.SetLevel(parsed)
+	}
+
+	writer, colored, err := ctx.writer(config)
+	if err != nil {
+		return nil, level, err
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	if config.isDev() {
+		encoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.FunctionKey = "func"
+	encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339Nano)
+
+	var encoder zapcore.Encoder
+	switch config.Encoding {
+	case EncodingJSON:
+		encoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case EncodingConsole:
+		// Colours only make sense on a terminal
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if colored {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, level, fmt.Errorf("unknown log encoding %q", config.Encoding)
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.AddSync(writer), level)
+	if config.SamplingThereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, config.SamplingInitial, config.SamplingThereafter)
+	}
+	return core, level, nil
 }
 
-// close closes the logger.
-func (ctx *zapSession) close() error {
+// writer returns the destination of log entries and whether it supports colours.
+func (ctx *zapSession) writer(config LoggerConfig) (io.Writer, bool, error) {
+	switch config.Output {
+	case "", "stdout":
+		return colorable.NewColorableStdout(), true, nil
+	case "stderr":
+		return colorable.NewColorableStderr(), true, nil
+	}
+	if config.MaxSizeMB <= 0 {
+		return nil, false, fmt.Errorf("log file max size must be positive")
+	}
+	return &lumberjack.Logger{
+		Filename:   config.Output,
+		MaxSize:    config.MaxSizeMB,
+		MaxAge:     config.MaxAgeDays,
+		MaxBackups: config.MaxBackups,
+		Compress:   config.Compress,
+	}, false, nil
+}
+
+// Sync flushes buffered log entries, it should be called before the process exits.
+func (ctx *zapSession) Sync() error {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	if ctx.zap == nil {
+		return nil
+	}
 	return ctx.zap.Sync()
 }
 
-// logLeveler returns the log level for the logger.
-func (ctx *zapSession) logLeveler() zapcore.LevelEnabler {
-	if ctx.environment == "Dev" || ctx.environment == "dev" {
+// GetLevel returns the current minimum log level.
+func (ctx *zapSession) GetLevel() string {
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	return ctx.level.String()
+}
+
+// SetLevel changes the minimum log level at runtime.
+func (ctx *zapSession) SetLevel(level string) error {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	ctx.mutex.RLock()
+	defer ctx.mutex.RUnlock()
+
+	ctx.level.SetLevel(parsed)
+	return nil
+}
+
+// logLeveler returns the default log level for the environment.
+func (ctx *zapSession) logLeveler(config LoggerConfig) zapcore.Level {
+	if config.isDev() {
 		return zapcore.DebugLevel
 	}
 	return zapcore.InfoLevel
@@ -0,0 +1,74 @@
+package utilities
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Checkout failure reasons reported in the checkout_failures_total metric
+const (
+	FailureOutOfStock      = "out_of_stock"
+	FailureInvalidCoupon   = "invalid_coupon"
+	FailureCouponExpired   = "coupon_expired"
+	FailureEmptyCart       = "empty_cart"
+	FailurePaymentDeclined = "payment_declined"
+	FailureOther           = "other"
+)
+
+// metricsRegistry holds the Prometheus collectors of the service.
+// Business metrics are labelled with the tenant, which is empty when the process serves a single store.
+type metricsRegistry struct {
+	Registry          *prometheus.Registry       // Registry exposed on /metrics
+	HTTPRequests      *prometheus.CounterVec     // HTTP requests by tenant, method, route and status
+	HTTPDuration      *prometheus.HistogramVec   // HTTP request latency by tenant, method, route and status
+	OrdersPlaced      *prometheus.CounterVec     // Orders placed successfully by tenant
+	CheckoutFailures  *prometheus.CounterVec     // Failed checkouts by tenant and reason
+	Revenue           *prometheus.CounterVec     // Amount paid on placed orders by tenant
+	CouponsIssued     *prometheus.CounterVec     // Discount coupons handed out by tenant
+}
+
+// newMetricsRegistry creates the collectors and registers them on a new registry.
+func newMetricsRegistry() *metricsRegistry {
+	m := &metricsRegistry{
+		Registry: prometheus.NewRegistry(),
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by tenant, method, route and status.",
+		}, []string{"tenant", "method", "route", "status"}),
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by tenant, method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tenant", "method", "route", "status"}),
+		OrdersPlaced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "orders_placed_total",
+			Help: "Number of orders placed by tenant.",
+		}, []string{"tenant"}),
+		CheckoutFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "checkout_failures_total",
+			Help: "Number of failed checkouts by tenant and reason.",
+		}, []string{"tenant", "reason"}),
+		Revenue: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "revenue_total",
+			Help: "Amount paid on placed orders by tenant.",
+		}, []string{"tenant"}),
+		CouponsIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "coupons_issued_total",
+			Help: "Number of discount coupons issued by tenant.",
+		}, []string{"tenant"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests,
+		m.HTTPDuration,
+		m.OrdersPlaced,
+		m.CheckoutFailures,
+		m.Revenue,
+		m.CouponsIssued,
+	)
+	return m
+}
+
+// Metrics is a global instance of metricsRegistry.
+var Metrics = newMetricsRegistry()
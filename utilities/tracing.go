@@ -0,0 +1,59 @@
+package utilities
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Environment variable selecting the trace exporter (otlp, stdout or none)
+const TracingExporterEnv = "TRACING_EXPORTER"
+
+// Trace exporters accepted by SetupTracing
+const (
+	TracingExporterNone   = "none"   // Tracing disabled
+	TracingExporterStdout = "stdout" // Spans printed to stdout, useful in development
+	TracingExporterOTLP   = "otlp"   // Spans sent over OTLP/HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+)
+
+// SetupTracing installs the global tracer provider with the given exporter and returns a function that flushes and stops it.
+func SetupTracing(ctx context.Context, exporterName string, serviceName string) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case "", TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case TracingExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporterName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewTracerProvider(serviceName, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	// Continue traces started by callers and pass them on
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider that tags spans with the service name.
+func NewTracerProvider(serviceName string, options ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, options...)...)
+}